@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"os"
+	"strconv"
+	"sync"
+)
+
+var (
+	pullFailureCount   = 0
+	pullFailureAlerted = false
+	pullFailureMutex   = sync.Mutex{}
+)
+
+// The number of background pulls in a row that have to fail, before the admin gets an alert.
+// This is set with PULL_FAILURE_THRESHOLD, by default 3 (one and a half hours).
+func pullFailureThreshold() (int, error) {
+	if os.Getenv("PULL_FAILURE_THRESHOLD") == "" {
+		return 3, nil
+	}
+	threshold, err := strconv.Atoi(os.Getenv("PULL_FAILURE_THRESHOLD"))
+	if err != nil || threshold < 1 {
+		return 0, fmt.Errorf("PULL_FAILURE_THRESHOLD must be a positive number")
+	}
+	return threshold, nil
+}
+
+// Count a failed background pull and tell the admin once too many failed in a row. Without the alert an expired
+// access token would silently stop all notifications.
+func recordPullFailure(bot *tgbotapi.BotAPI, err error) {
+	pullFailureMutex.Lock()
+	defer pullFailureMutex.Unlock()
+
+	pullFailureCount++
+	threshold, _ := pullFailureThreshold()
+	if pullFailureAlerted || pullFailureCount < threshold {
+		return
+	}
+
+	pullFailureAlerted = true
+	sendMessageTo(bot, int64(getAdmin()), fmt.Sprintf("⚠️ *Pulling the repository failed %d times in a row*\n"+
+		"Until this is fixed nobody gets notified about new commits. Maybe the access token expired?\n`Error: %s`",
+		pullFailureCount, err.Error()))
+}
+
+// Reset the failure count after a background pull worked, if the admin got an alert they are told it is fixed
+func recordPullSuccess(bot *tgbotapi.BotAPI) {
+	pullFailureMutex.Lock()
+	defer pullFailureMutex.Unlock()
+
+	if pullFailureAlerted {
+		sendMessageTo(bot, int64(getAdmin()), fmt.Sprintf("✅ *Pulling the repository works again*\n"+
+			"It failed %d times in a row before.", pullFailureCount))
+	}
+	pullFailureCount = 0
+	pullFailureAlerted = false
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	gitobject "github.com/go-git/go-git/v5/plumbing/object"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Telegram only allows bots to upload files up to 50 MB
+const maxUploadSize = 50 * 1024 * 1024
+
+var errArchiveTooLarge = fmt.Errorf("the archive is larger than the %d MB Telegram allows bots to upload", maxUploadSize/1024/1024)
+
+// A writer that fails once more than limit bytes got written to it
+type limitedWriter struct {
+	writer  io.Writer
+	written int64
+	limit   int64
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.written+int64(len(p)) > w.limit {
+		return 0, errArchiveTooLarge
+	}
+	n, err := w.writer.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Write a zip archive of a directory in the repository as it was at the commit rev into out. If rev is empty, HEAD
+// is used. The archive is streamed, so out should be a file and not a buffer in memory. The returned name is a
+// filename suggestion for the archive.
+func zipDirectory(dir string, rev string, out io.Writer) (name string, err error) {
+	dir = strings.TrimPrefix(path.Clean("/"+dir), "/")
+	if err := checkPath(dir); err != nil {
+		return "", err
+	}
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	// Find the tree of the directory at the commit
+	tree, commit, err := treeAt(rev)
+	if err != nil {
+		return "", err
+	}
+	if dir != "" {
+		tree, err = tree.Tree(dir)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Add all files of the tree to the archive
+	archive := zip.NewWriter(&limitedWriter{writer: out, limit: maxUploadSize})
+	err = tree.Files().ForEach(func(f *gitobject.File) error {
+		// The archive should contain the same files a user could download one by one
+		if checkPath(path.Join(dir, f.Name)) != nil {
+			return nil
+		}
+
+		header := &zip.FileHeader{
+			Name:     f.Name,
+			Method:   zip.Deflate,
+			Modified: commit.Committer.When,
+		}
+		if mode, err := f.Mode.ToOSFileMode(); err == nil {
+			header.SetMode(mode)
+		}
+		writer, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		reader, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		_, err = io.Copy(writer, reader)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := archive.Close(); err != nil {
+		return "", err
+	}
+
+	// Name the archive after the directory and the commit it was created from
+	base := path.Base(dir)
+	if dir == "" {
+		base = "repository"
+	}
+	name = fmt.Sprintf("%s-%s.zip", base, commit.Hash.String()[:7])
+	return name, nil
+}
+
+// Write the archive of zipDirectory into a temporary file, so it doesn't have to be kept in memory. The returned file
+// is at its beginning and the caller must close and remove it.
+func zipDirectoryToFile(dir string, rev string) (name string, file *os.File, size int64, err error) {
+	file, err = ioutil.TempFile("", "ep2-bot-*.zip")
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	name, err = zipDirectory(dir, rev, file)
+	if err == nil {
+		size, err = file.Seek(0, io.SeekCurrent)
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return "", nil, 0, err
+	}
+	return name, file, size, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestZipDirectoryToFile(t *testing.T) {
+	defer enterTempDir(t)()
+	repo := newTestRepo(t, getGitDir(), false)
+	repo.commit("readme", 1000, 1000)
+	if err := os.MkdirAll(getGitDir()+"/ue1/src", 0777); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"ue1/angabe.txt", "ue1/src/Main.java"} {
+		if err := ioutil.WriteFile(getGitDir()+"/"+file, []byte(file+"\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.git("add", "ue1")
+	hash := repo.commit("ue1", 2000, 2000)
+
+	name, file, size, err := zipDirectoryToFile("ue1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if name != "ue1-"+hash[:7]+".zip" {
+		t.Errorf("expected the name ue1-%s.zip, got %s", hash[:7], name)
+	}
+
+	// The archive is read from the start of the file, like the upload does
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if expected := []string{"angabe.txt", "src/Main.java"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected the files %v, got %v", expected, names)
+	}
+}
+
+func TestLimitedWriter(t *testing.T) {
+	var out strings.Builder
+	writer := &limitedWriter{writer: &out, limit: 10}
+	if _, err := writer.Write([]byte("12345")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte("67890")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte("x")); err != errArchiveTooLarge {
+		t.Errorf("expected errArchiveTooLarge, got %v", err)
+	}
+	if out.String() != "1234567890" {
+		t.Errorf("expected only the content within the limit to be written, got %q", out.String())
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Read a secret either directly from the environment variable name or from the file the variable name_FILE
+// points to, which works well with docker secrets.
+// An empty string is returned if neither is set.
+func readSecret(name string) (string, error) {
+	if value := os.Getenv(name); value != "" {
+		return value, nil
+	}
+
+	file := os.Getenv(name + "_FILE")
+	if file == "" {
+		return "", nil
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s_FILE: %w", name, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Parse the GIT_URL, this also understands the scp-like syntax of ssh (git@host:path.git)
+func gitEndpoint() (*transport.Endpoint, error) {
+	return transport.NewEndpoint(os.Getenv("GIT_URL"))
+}
+
+// Create the authentication for the origin from the environment.
+// With GIT_SSH_KEY a private key is used (verified against GIT_KNOWN_HOSTS or the default known_hosts files),
+// otherwise GIT_TOKEN or GIT_PASSWORD are used for HTTP basic auth.
+// If nothing is configured nil is returned and the credentials in GIT_URL (if any) are used.
+func gitAuth() (transport.AuthMethod, error) {
+	endpoint, err := gitEndpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	// SSH with a private key
+	if keyFile := os.Getenv("GIT_SSH_KEY"); keyFile != "" {
+		if endpoint.Protocol != "ssh" {
+			return nil, fmt.Errorf("GIT_SSH_KEY is set but GIT_URL is not an ssh URL")
+		}
+		keyPassword, err := readSecret("GIT_SSH_KEY_PASSWORD")
+		if err != nil {
+			return nil, err
+		}
+
+		// The ssh user is almost always git, the matriculation number is set with GIT_USERNAME
+		user := endpoint.User
+		if user == "" {
+			user = "git"
+		}
+		auth, err := gitssh.NewPublicKeysFromFile(user, keyFile, keyPassword)
+		if err != nil {
+			return nil, fmt.Errorf("could not load GIT_SSH_KEY: %w", err)
+		}
+
+		// Without GIT_KNOWN_HOSTS go-git uses SSH_KNOWN_HOSTS or ~/.ssh/known_hosts, but never skips the check
+		if knownHosts := os.Getenv("GIT_KNOWN_HOSTS"); knownHosts != "" {
+			auth.HostKeyCallback, err = gitssh.NewKnownHostsCallback(knownHosts)
+			if err != nil {
+				return nil, fmt.Errorf("could not load GIT_KNOWN_HOSTS: %w", err)
+			}
+		}
+		return auth, nil
+	}
+
+	// HTTP with a personal access token or password
+	token, err := readSecret("GIT_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	password, err := readSecret("GIT_PASSWORD")
+	if err != nil {
+		return nil, err
+	}
+	if token == "" && password == "" {
+		return nil, nil
+	}
+	if endpoint.Protocol != "http" && endpoint.Protocol != "https" {
+		return nil, fmt.Errorf("GIT_TOKEN and GIT_PASSWORD only work with http(s) URLs")
+	}
+
+	username := os.Getenv("GIT_USERNAME")
+	if token != "" {
+		// GitLab accepts personal access tokens with any username, but it must not be empty
+		if username == "" {
+			username = "oauth2"
+		}
+		return &githttp.BasicAuth{Username: username, Password: token}, nil
+	}
+	if username == "" {
+		return nil, fmt.Errorf("GIT_PASSWORD is set but GIT_USERNAME is not")
+	}
+	return &githttp.BasicAuth{Username: username, Password: password}, nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The number of directory entries shown on one page of the browser
+const browsePageSize = 10
+
+// The number of locations kept in memory for the buttons, the buttons of the least recently shown ones expire
+const maxBrowsePaths = 1000
+
+// A path in the repository the browser can show, if Rev is empty it is the path in the working tree
+type browseLocation struct {
+	Path string
+	Rev  string
+}
+
+var (
+	// Telegram only allows 64 bytes of callback data, which is not enough for long paths. So the buttons only carry a
+	// short id and the location behind it is stored here.
+	browsePaths     = make(map[string]browseLocation)
+	browsePathOrder = make([]string, 0, maxBrowsePaths)
+	browsePathMutex = sync.Mutex{}
+)
+
+// Get a short id for the location, which can be used in the callback data of a button
+func browseID(loc browseLocation) string {
+	sum := sha1.Sum([]byte(loc.Rev + "@" + loc.Path))
+	id := hex.EncodeToString(sum[:])[:12]
+
+	browsePathMutex.Lock()
+	defer browsePathMutex.Unlock()
+
+	// The location is shown again, so it moves to the end of the order
+	if _, ok := browsePaths[id]; ok {
+		for i, other := range browsePathOrder {
+			if other == id {
+				browsePathOrder = append(browsePathOrder[:i], browsePathOrder[i+1:]...)
+				break
+			}
+		}
+	} else if len(browsePathOrder) == maxBrowsePaths {
+		// Forget the least recently shown location if there are too many
+		delete(browsePaths, browsePathOrder[0])
+		browsePathOrder = browsePathOrder[1:]
+	}
+
+	browsePaths[id] = loc
+	browsePathOrder = append(browsePathOrder, id)
+	return id
+}
+
+// Get the location back from an id created by browseID
+func browsePath(id string) (browseLocation, bool) {
+	browsePathMutex.Lock()
+	defer browsePathMutex.Unlock()
+	loc, ok := browsePaths[id]
+	return loc, ok
+}
+
+// Get the location of the parent directory
+func (loc browseLocation) parent() browseLocation {
+	return browseLocation{Path: cleanBrowsePath(path.Dir(loc.Path)), Rev: loc.Rev}
+}
+
+// Get the location of an entry in the directory
+func (loc browseLocation) child(name string) browseLocation {
+	return browseLocation{Path: path.Join(loc.Path, name), Rev: loc.Rev}
+}
+
+// Format the location for the text of the browser message
+func (loc browseLocation) String() string {
+	if loc.Rev == "" {
+		return fmt.Sprintf("`/%s`", loc.Path)
+	}
+	return fmt.Sprintf("`/%s` at `%s`", loc.Path, loc.Rev)
+}
+
+// Normalize a path the user typed to the form used by the browser, which is relative to the repository root and
+// "" is the root itself
+func cleanBrowsePath(p string) string {
+	p = strings.TrimPrefix(path.Clean("/"+strings.TrimSpace(p)), "/")
+	return p
+}
+
+// Create the text and keyboard to show a page of a directory
+func renderDirectory(dir browseLocation, page int) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	entries, err := listFiles(dir.Path, dir.Rev)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	// Make sure the page is in range, since the directory might have changed since the button was created
+	pages := (len(entries) + browsePageSize - 1) / browsePageSize
+	if pages == 0 {
+		pages = 1
+	}
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+
+	// Add a button for each entry of the current page
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, browsePageSize+1)
+	end := (page + 1) * browsePageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+	for _, entry := range entries[page*browsePageSize : end] {
+		var button tgbotapi.InlineKeyboardButton
+		if entry.IsDir {
+			button = tgbotapi.NewInlineKeyboardButtonData("📁 "+entry.Name, "ls "+browseID(dir.child(entry.Name))+" 0")
+		} else {
+			button = tgbotapi.NewInlineKeyboardButtonData("📄 "+entry.Name, "file "+browseID(dir.child(entry.Name)))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+	}
+
+	// Add the navigation row with the up and the pagination buttons
+	navigation := make([]tgbotapi.InlineKeyboardButton, 0, 3)
+	if dir.Path != "" {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("⬆️ Up", "ls "+browseID(dir.parent())+" 0"))
+	}
+	if page > 0 {
+		callback := fmt.Sprintf("ls %s %d", browseID(dir), page-1)
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("◀️", callback))
+	}
+	if page < pages-1 {
+		callback := fmt.Sprintf("ls %s %d", browseID(dir), page+1)
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("▶️", callback))
+	}
+	if len(navigation) > 0 {
+		rows = append(rows, navigation)
+	}
+
+	text := fmt.Sprintf("📁 %s\n%d entries", dir, len(entries))
+	if pages > 1 {
+		text += fmt.Sprintf(", page %d/%d", page+1, pages)
+	}
+	if len(entries) == 0 {
+		text = fmt.Sprintf("📁 %s\nThis directory is empty", dir)
+	}
+	return text, tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// Create the text and keyboard to show the actions for a single file
+func renderFile(file browseLocation) (string, tgbotapi.InlineKeyboardMarkup) {
+	id := browseID(file)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👁 View", "view "+id),
+			tgbotapi.NewInlineKeyboardButtonData("⬇️ Download", "dl "+id),
+			tgbotapi.NewInlineKeyboardButtonData("🕓 History", "log "+id),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Back", "ls "+browseID(file.parent())+" 0"),
+		),
+	)
+
+	return fmt.Sprintf("📄 %s", file), keyboard
+}
+
+// Replace the content of the browser message
+func editBrowser(bot *tgbotapi.BotAPI, message *tgbotapi.Message, text string, keyboard tgbotapi.InlineKeyboardMarkup) {
+	edit := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, hideSecrets(text))
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = &keyboard
+	_, _ = send(bot, edit)
+}
+
+// Handle all callbacks created by the browser, returns false if the data wasn't a browser callback
+func handleBrowseCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, data []string) bool {
+	switch data[0] {
+	case "ls", "file", "view", "dl", "log":
+	default:
+		return false
+	}
+
+	// Only the admin is allowed to browse the files
+	if !isAdmin(query.From.ID) {
+		_, _ = bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(query.ID, "Only the admin is allowed to browse the files."))
+		return true
+	}
+
+	// The ids are only stored in memory, so they are lost after a restart
+	var fields []string
+	if len(data) > 1 {
+		fields = strings.Fields(data[1])
+	}
+	file, ok := browseLocation{}, len(fields) > 0
+	if ok {
+		file, ok = browsePath(fields[0])
+	}
+	if !ok {
+		_, _ = bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(query.ID, "This browser expired, please send /ls again."))
+		return true
+	}
+	_, _ = bot.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, ""))
+
+	chatID := query.Message.Chat.ID
+	switch data[0] {
+	case "ls":
+		page := 0
+		if len(fields) > 1 {
+			page, _ = strconv.Atoi(fields[1])
+		}
+
+		text, keyboard, err := renderDirectory(file, page)
+		if err != nil {
+			logger.WithError(err).WithField("chat_id", chatID).Warn("Unable to list the files")
+			sendMessageTo(bot, chatID, fmt.Sprintf("An error occoured while listing the files.\n`Error: %s`", err.Error()))
+			return true
+		}
+		editBrowser(bot, query.Message, text, keyboard)
+	case "file":
+		text, keyboard := renderFile(file)
+		editBrowser(bot, query.Message, text, keyboard)
+	case "view":
+		sendFileContent(bot, chatID, file.Path, file.Rev)
+	case "dl":
+		if file.Rev == "" {
+			sendFileTo(bot, chatID, path.Join(getGitDir(), file.Path))
+			return true
+		}
+
+		content, err := readFile(file.Path, file.Rev)
+		if err != nil {
+			sendMessageTo(bot, chatID, fmt.Sprintf("Unable to send you the file\n`Error: %s`", err.Error()))
+			return true
+		}
+		sendBytesTo(bot, chatID, path.Base(file.Path), content)
+	case "log":
+		commits, err := fileHistory(file.Path)
+		if err != nil {
+			logger.WithError(err).WithField("chat_id", chatID).Warn("Unable to read the history")
+			sendMessageTo(bot, chatID, fmt.Sprintf("An error occoured while reading the history.\n`Error: %s`", err.Error()))
+			return true
+		}
+
+		// Private commits are hidden if the admin uses the browser in a group chat
+		if !showsPrivate(query.From.ID, chatID) {
+			commits = filterPrivateCommits(commits)
+		}
+		if len(commits) == 0 {
+			sendMessageTo(bot, chatID, fmt.Sprintf("There are no commits for `%s`", file.Path))
+			return true
+		}
+
+		// Only show the latest commits, like /history does
+		if len(commits) > 5 {
+			commits = commits[len(commits)-5:]
+		}
+		message := fmt.Sprintf("*History of* `%s`\n\n", file.Path)
+		for _, commit := range commits {
+			message += formatCommit(commit)
+		}
+		sendMessageTo(bot, chatID, message)
+	}
+
+	return true
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The format of times in iCalendar files, always in UTC
+const icsTimeFormat = "20060102T150405Z"
+
+// An event in the calendar
+type calendarEvent struct {
+	UID     string
+	Summary string
+	Start   time.Time
+	End     time.Time
+}
+
+// Escape text for iCalendar (RFC 5545 section 3.3.11)
+func icsEscape(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	return replacer.Replace(text)
+}
+
+// Write a content line, lines longer than 75 bytes must be folded
+func icsLine(builder *strings.Builder, line string) {
+	for len(line) > 75 {
+		// Don't split UTF-8 characters
+		cut := 75
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		builder.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	builder.WriteString(line + "\r\n")
+}
+
+// Find the time each exercise was released, which is the first commit that added one of its files
+func exerciseReleases() (map[int]time.Time, error) {
+	exercises, err := exerciseCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make(map[int]time.Time)
+	for _, e := range exercises {
+		for _, file := range e.Files {
+			commits, err := fileHistory(file)
+			if err != nil {
+				return nil, err
+			}
+			if len(commits) == 0 {
+				continue
+			}
+			released := commits[0].Committer.When
+			if current, ok := releases[e.Number]; !ok || released.Before(current) {
+				releases[e.Number] = released
+			}
+		}
+	}
+	return releases, nil
+}
+
+// Collect the releases and deadlines of all exercises
+func calendarEvents() ([]calendarEvent, error) {
+	releases, err := exerciseReleases()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]calendarEvent, 0)
+	for number, released := range releases {
+		events = append(events, calendarEvent{
+			UID:     fmt.Sprintf("release-%d@ep2-bot", number),
+			Summary: fmt.Sprintf("Exercise %d released", number),
+			Start:   released,
+			End:     released,
+		})
+	}
+	for _, d := range getDeadlines() {
+		events = append(events, calendarEvent{
+			UID:     fmt.Sprintf("deadline-%d@ep2-bot", d.ID),
+			Summary: fmt.Sprintf("Deadline: %s", d.Title),
+			Start:   d.Due,
+			End:     d.Due,
+		})
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+	return events, nil
+}
+
+// Create an iCalendar file with the releases and deadlines of all exercises
+func createCalendar() ([]byte, error) {
+	events, err := calendarEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	var builder strings.Builder
+	now := time.Now().UTC().Format(icsTimeFormat)
+	icsLine(&builder, "BEGIN:VCALENDAR")
+	icsLine(&builder, "VERSION:2.0")
+	icsLine(&builder, "PRODID:-//flofriday//EP2-Bot//EN")
+	icsLine(&builder, "CALSCALE:GREGORIAN")
+	icsLine(&builder, "X-WR-CALNAME:"+icsEscape("EP2 Exercises"))
+	for _, event := range events {
+		icsLine(&builder, "BEGIN:VEVENT")
+		icsLine(&builder, "UID:"+event.UID)
+		icsLine(&builder, "DTSTAMP:"+now)
+		icsLine(&builder, "DTSTART:"+event.Start.UTC().Format(icsTimeFormat))
+		icsLine(&builder, "DTEND:"+event.End.UTC().Format(icsTimeFormat))
+		icsLine(&builder, "SUMMARY:"+icsEscape(event.Summary))
+		icsLine(&builder, "END:VEVENT")
+	}
+	icsLine(&builder, "END:VCALENDAR")
+	return []byte(builder.String()), nil
+}
+
+// Serve the calendar, so calendar apps can subscribe to it. If CALENDAR_TOKEN is set the feed is only served with
+// ?token=<CALENDAR_TOKEN>.
+func calendarHandler(w http.ResponseWriter, r *http.Request) {
+	token := os.Getenv("CALENDAR_TOKEN")
+	if token != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(token)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	content, err := createCalendar()
+	if err != nil {
+		logger.WithError(err).Error("Unable to create the calendar")
+		http.Error(w, "unable to create the calendar", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="ep2.ics"`)
+	_, _ = w.Write(content)
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	gitobject "github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The version of the cache entries, entries with an other version are created again when the cache is loaded
+const commitCacheVersion = 1
+
+// The information about a commit that is needed to answer history queries without walking through the repository
+type cachedCommit struct {
+	Version       int
+	Hash          string
+	Parents       []string
+	AuthorEmail   string
+	AuthorWhen    time.Time
+	CommitterWhen time.Time
+	// The files changed compared to the first parent, for renamed files this contains the new and the old name
+	Files []string
+	// The files renamed compared to the first parent, the key is the new name and the value the old one
+	Renames map[string]string `json:",omitempty"`
+}
+
+var (
+	// All commits that were ever seen in the repository, even the ones that are no longer reachable after a rewrite
+	commitCache = make(map[string]*cachedCommit)
+	// The hashes of the cached commits, so walking the repository can stop at the commits that are already known
+	commitCacheSeen = make(map[plumbing.Hash]bool)
+	// Changes every time commits are added to the cache
+	commitCacheGeneration = 0
+
+	// The commits reachable from the last commit that was queried. Most queries start at HEAD, so the graph only
+	// has to be walked again after the cache changed.
+	reachableMemo struct {
+		From       string
+		Generation int
+		Commits    []*cachedCommit
+	}
+
+	commitCacheMutex = sync.Mutex{}
+	commitCacheFile  = path.Join("data", "commits.json")
+)
+
+// Load the commit cache from the disk.
+// This function should be called once. The cache only contains information from the repository, so if the file is
+// broken the cache just starts empty and gets filled again by updateCommitCache.
+func loadCommitCache() {
+	commitCacheMutex.Lock()
+	defer commitCacheMutex.Unlock()
+
+	// Read the file
+	byteValue, err := ioutil.ReadFile(commitCacheFile)
+	if err != nil {
+		logger.Info("The commit cache file does not exist")
+		return
+	}
+
+	// Parse the file
+	var commits []*cachedCommit
+	err = json.Unmarshal(byteValue, &commits)
+	if err != nil {
+		logger.WithError(err).Warn("Unable to parse the commit cache file, the cache gets rebuilt")
+		return
+	}
+	for _, c := range commits {
+		if c.Version == commitCacheVersion {
+			addCachedCommit(c)
+		}
+	}
+}
+
+// Add a commit to the cache
+// Note: the caller must lock the commitCacheMutex
+func addCachedCommit(c *cachedCommit) {
+	commitCache[c.Hash] = c
+	commitCacheSeen[plumbing.NewHash(c.Hash)] = true
+	commitCacheGeneration++
+}
+
+// Save the commit cache to the disk
+// Note: the caller must lock the commitCacheMutex to avoid race conditions
+func saveCommitCache() error {
+	commits := make([]*cachedCommit, 0, len(commitCache))
+	for _, c := range commitCache {
+		commits = append(commits, c)
+	}
+
+	byteValue, err := json.Marshal(commits)
+	if err != nil {
+		return err
+	}
+
+	// Write a temporary file first and replace the old one with it, so a crash while writing can't leave a broken
+	// cache behind
+	tmpFile := commitCacheFile + ".tmp"
+	err = ioutil.WriteFile(tmpFile, byteValue, 0777)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, commitCacheFile)
+}
+
+// Add all commits reachable from HEAD, the branches and the tags to the cache, that are not in it yet.
+// This is called after every fetch, so the queries can rely on the cache being up to date.
+func updateCommitCache(log *logrus.Entry) error {
+	r, err := git.PlainOpen(getGitDir())
+	if err != nil {
+		return err
+	}
+
+	// Collect the commits all branches and tags point to
+	tips := make([]plumbing.Hash, 0)
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	tips = append(tips, head.Hash())
+	refs, err := trackedRefs(r)
+	if err != nil {
+		return err
+	}
+	for _, hash := range refs {
+		tips = append(tips, hash)
+	}
+
+	commitCacheMutex.Lock()
+	defer commitCacheMutex.Unlock()
+
+	// Walk from every tip until we reach commits we already know
+	added := 0
+	for _, tip := range tips {
+		if commitCacheSeen[tip] {
+			continue
+		}
+
+		commit, err := repoRefCommit(r, tip)
+		if err != nil {
+			return err
+		}
+		if commitCacheSeen[commit.Hash] {
+			continue
+		}
+
+		err = gitobject.NewCommitPreorderIter(commit, commitCacheSeen, nil).ForEach(func(c *gitobject.Commit) error {
+			cached, err := newCachedCommit(c)
+			if err != nil {
+				return err
+			}
+			addCachedCommit(cached)
+			added++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if added == 0 {
+		return nil
+	}
+	log.WithField("commits", added).Info("Updated the commit cache")
+	return saveCommitCache()
+}
+
+// Create the cache entry for a commit
+func newCachedCommit(c *gitobject.Commit) (*cachedCommit, error) {
+	files, renames, err := changedFiles(c)
+	if err != nil {
+		return nil, err
+	}
+
+	parents := make([]string, 0, len(c.ParentHashes))
+	for _, parent := range c.ParentHashes {
+		parents = append(parents, parent.String())
+	}
+
+	return &cachedCommit{
+		Version:       commitCacheVersion,
+		Hash:          c.Hash.String(),
+		Parents:       parents,
+		AuthorEmail:   c.Author.Email,
+		AuthorWhen:    c.Author.When,
+		CommitterWhen: c.Committer.When,
+		Files:         files,
+		Renames:       renames,
+	}, nil
+}
+
+// Get the names of the files a commit changed compared to its first parent and the files it renamed
+func changedFiles(c *gitobject.Commit) ([]string, map[string]string, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The first commit has no parent, so it is compared to an empty tree
+	parentTree := &gitobject.Tree{}
+	if c.NumParents() > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, nil, err
+		}
+		parentTree, err = parent.Tree()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Comparing files for renames is expensive, so it is limited for commits which change a lot of files
+	options := &gitobject.DiffTreeOptions{DetectRenames: true, RenameScore: 60, RenameLimit: 100}
+	changes, err := gitobject.DiffTreeWithOptions(context.Background(), parentTree, tree, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := make([]string, 0, len(changes))
+	var renames map[string]string
+	for _, change := range changes {
+		switch {
+		case change.From.Name == "":
+			files = append(files, change.To.Name)
+		case change.To.Name == "", change.From.Name == change.To.Name:
+			files = append(files, change.From.Name)
+		default:
+			files = append(files, change.To.Name, change.From.Name)
+			if renames == nil {
+				renames = make(map[string]string)
+			}
+			renames[change.To.Name] = change.From.Name
+		}
+	}
+	return files, renames, nil
+}
+
+// Get all cached commits reachable from the commit from
+func reachableCommits(from plumbing.Hash) ([]*cachedCommit, error) {
+	return cachedRange(plumbing.ZeroHash, from)
+}
+
+// Get all cached commits reachable from until, that are not reachable from since (like git log since..until).
+// If since is the zero hash, all commits reachable from until are returned.
+// The cache is updated with every fetch, only commits that are no longer on a branch need to be added here.
+func cachedRange(since, until plumbing.Hash) ([]*cachedCommit, error) {
+	// Make sure both commits are in the cache
+	if !since.IsZero() {
+		if err := cacheCommit(since); err != nil {
+			return nil, err
+		}
+	}
+	if err := cacheCommit(until); err != nil {
+		return nil, err
+	}
+
+	commitCacheMutex.Lock()
+	defer commitCacheMutex.Unlock()
+
+	// The callers sort and filter the list, so they get their own copy of the memo
+	memo := &reachableMemo
+	if since.IsZero() && memo.From == until.String() && memo.Generation == commitCacheGeneration {
+		return append([]*cachedCommit(nil), memo.Commits...), nil
+	}
+
+	excluded := make(map[string]bool)
+	if !since.IsZero() {
+		walkCache(since.String(), excluded, nil)
+	}
+
+	commits := make([]*cachedCommit, 0)
+	walkCache(until.String(), excluded, func(c *cachedCommit) {
+		commits = append(commits, c)
+	})
+
+	if since.IsZero() {
+		memo.From, memo.Generation, memo.Commits = until.String(), commitCacheGeneration, commits
+		return append([]*cachedCommit(nil), commits...), nil
+	}
+	return commits, nil
+}
+
+// Walk through the cached graph starting at from. The visited commits are added to seen and commits already in
+// seen are not visited.
+// Note: the caller must lock the commitCacheMutex
+func walkCache(from string, seen map[string]bool, visit func(c *cachedCommit)) {
+	stack := []string{from}
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		c, ok := commitCache[hash]
+		if !ok || seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		if visit != nil {
+			visit(c)
+		}
+		stack = append(stack, c.Parents...)
+	}
+}
+
+// Make sure a single commit and its history is in the cache, even if no branch points to it anymore
+func cacheCommit(hash plumbing.Hash) error {
+	commitCacheMutex.Lock()
+	_, ok := commitCache[hash.String()]
+	commitCacheMutex.Unlock()
+	if ok {
+		return nil
+	}
+
+	r, err := git.PlainOpen(getGitDir())
+	if err != nil {
+		return err
+	}
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+
+	commitCacheMutex.Lock()
+	defer commitCacheMutex.Unlock()
+	err = gitobject.NewCommitPreorderIter(commit, commitCacheSeen, nil).ForEach(func(c *gitobject.Commit) error {
+		cached, err := newCachedCommit(c)
+		if err != nil {
+			return err
+		}
+		addCachedCommit(cached)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return saveCommitCache()
+}
+
+// Load the full commit objects for the cached commits, the order is kept
+func loadCommits(cached []*cachedCommit) ([]gitobject.Commit, error) {
+	r, err := git.PlainOpen(getGitDir())
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make([]gitobject.Commit, 0, len(cached))
+	for _, c := range cached {
+		commit, err := r.CommitObject(plumbing.NewHash(c.Hash))
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, *commit)
+	}
+	return commits, nil
+}
+
+// Returns true if the commit changed the file at path or a file in the directory at path
+func (c *cachedCommit) touches(path string) bool {
+	for _, file := range c.Files {
+		if file == path || path == "" || strings.HasPrefix(file, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the commit was made by the user of the git URL
+func (c *cachedCommit) isOwn() bool {
+	return isOwnEmail(c.AuthorEmail)
+}
+
+// The orders in which a list of commits can be sorted
+type commitOrder int
+
+const (
+	// Parents come before their children and independent commits are sorted by the commit date
+	orderTopological commitOrder = iota
+	orderCommitDate
+	orderAuthorDate
+)
+
+// Get the order from the name the user typed, returns false if there is no order with that name
+func parseCommitOrder(name string) (commitOrder, bool) {
+	switch strings.ToLower(name) {
+	case "topo", "topology", "graph":
+		return orderTopological, true
+	case "date", "commit", "committer":
+		return orderCommitDate, true
+	case "author":
+		return orderAuthorDate, true
+	}
+	return orderTopological, false
+}
+
+// Sort the commits chronocally with the newest commit as last
+func sortCommits(commits []*cachedCommit, order commitOrder) {
+	// Even when sorting by date we first sort by the graph, so that commits with the same date are still in a
+	// sensible order
+	sortTopological(commits)
+
+	switch order {
+	case orderCommitDate:
+		sort.SliceStable(commits, func(i, j int) bool {
+			return commits[i].CommitterWhen.Before(commits[j].CommitterWhen)
+		})
+	case orderAuthorDate:
+		sort.SliceStable(commits, func(i, j int) bool {
+			return commits[i].AuthorWhen.Before(commits[j].AuthorWhen)
+		})
+	}
+}
+
+// Sort the commits so that every commit comes after all of its parents that are in the list
+func sortTopological(commits []*cachedCommit) {
+	index := make(map[string]int, len(commits))
+	for i, c := range commits {
+		index[c.Hash] = i
+	}
+
+	// Count for each commit how many of its parents are in the list and remember the children of each commit
+	pending := make([]int, len(commits))
+	children := make([][]int, len(commits))
+	for i, c := range commits {
+		for _, parent := range c.Parents {
+			if j, ok := index[parent]; ok {
+				pending[i]++
+				children[j] = append(children[j], i)
+			}
+		}
+	}
+
+	// A commit is ready once all its parents are sorted, of all ready commits the oldest one is taken next
+	ready := &commitHeap{commits: commits}
+	for i := range commits {
+		if pending[i] == 0 {
+			heap.Push(ready, i)
+		}
+	}
+	sorted := make([]*cachedCommit, 0, len(commits))
+	for ready.Len() > 0 {
+		i := heap.Pop(ready).(int)
+		sorted = append(sorted, commits[i])
+		for _, child := range children[i] {
+			pending[child]--
+			if pending[child] == 0 {
+				heap.Push(ready, child)
+			}
+		}
+	}
+
+	copy(commits, sorted)
+}
+
+// A heap of indices into commits, ordered by the commit date
+type commitHeap struct {
+	commits []*cachedCommit
+	items   []int
+}
+
+func (h commitHeap) Len() int { return len(h.items) }
+func (h commitHeap) Less(i, j int) bool {
+	return h.commits[h.items[i]].CommitterWhen.Before(h.commits[h.items[j]].CommitterWhen)
+}
+func (h commitHeap) Swap(i, j int)       { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *commitHeap) Push(x interface{}) { h.items = append(h.items, x.(int)) }
+func (h *commitHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
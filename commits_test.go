@@ -0,0 +1,176 @@
+package main
+
+import (
+	"github.com/go-git/go-git/v5/plumbing"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// The hashes of the cached commits in their order
+func cachedHashes(commits []*cachedCommit) []string {
+	hashes := make([]string, 0, len(commits))
+	for _, c := range commits {
+		hashes = append(hashes, c.Hash)
+	}
+	return hashes
+}
+
+// Create a repository with a merge:
+//
+//	base --- master ---- merge
+//	   \                /
+//	    `-- feature ---´
+func mergeRepo(t *testing.T) (base, feature, master, merge string) {
+	repo := newTestRepo(t, getGitDir(), false)
+	base = repo.commit("base", 1000, 1000)
+	repo.git("checkout", "-q", "-b", "feature")
+	feature = repo.commit("feature", 2000, 2000)
+	repo.git("checkout", "-q", "master")
+	master = repo.commit("master", 3000, 3000)
+	repo.run([]string{"GIT_AUTHOR_DATE=" + gitDate(4000), "GIT_COMMITTER_DATE=" + gitDate(4000)}, "-C", repo.dir,
+		"-c", "user.name=Tutor", "-c", "user.email=tutor@example.com", "-c", "commit.gpgsign=false",
+		"merge", "-q", "--no-ff", "-m", "merge", "feature")
+	merge = repo.git("rev-parse", "HEAD")
+	return
+}
+
+func TestHistoryRangeWithMerge(t *testing.T) {
+	defer enterTempDir(t)()
+	base, feature, master, merge := mergeRepo(t)
+
+	tests := []struct {
+		since, until string
+		expected     []string
+	}{
+		// Both sides of the merge are new, sorted by the graph and then by date
+		{base, merge, []string{feature, master, merge}},
+		// Commits of the merged branch that were already known are left out
+		{feature, merge, []string{master, merge}},
+		{master, merge, []string{feature, merge}},
+		// Like git log master..feature, the since commit doesn't have to be an ancestor
+		{master, feature, []string{feature}},
+		{merge, merge, []string{}},
+		{"", merge, []string{base, feature, master, merge}},
+	}
+	for _, test := range tests {
+		commits, err := historyRange(plumbing.NewHash(test.since), plumbing.NewHash(test.until))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hashes := commitHashes(commits); !reflect.DeepEqual(hashes, test.expected) {
+			t.Errorf("historyRange(%.7s, %.7s) = %v, expected %v", test.since, test.until, hashes, test.expected)
+		}
+	}
+}
+
+func TestMergeCommitFiles(t *testing.T) {
+	defer enterTempDir(t)()
+	_, _, _, merge := mergeRepo(t)
+
+	commits, err := reachableCommits(plumbing.NewHash(merge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range commits {
+		if c.Hash != merge {
+			continue
+		}
+		// A merge is compared to its first parent, so it brings the files of the merged branch
+		files := append([]string(nil), c.Files...)
+		sort.Strings(files)
+		if !reflect.DeepEqual(files, []string{"feature.txt"}) {
+			t.Errorf("expected the merge to change feature.txt, got %v", files)
+		}
+		if len(c.Parents) != 2 {
+			t.Errorf("expected the merge to have two parents, got %v", c.Parents)
+		}
+		return
+	}
+	t.Fatal("the merge commit is not in the cache")
+}
+
+func TestSortCommitsWithSkewedDates(t *testing.T) {
+	defer enterTempDir(t)()
+
+	// The clock of the second commit was wrong, so its commit date is before the one of its parent. The author
+	// date of the branch commit is older than all others, because it was rebased.
+	repo := newTestRepo(t, getGitDir(), false)
+	first := repo.commit("first", 1000, 1000)
+	skewed := repo.commit("skewed", 3000, 500)
+	repo.git("checkout", "-q", "-b", "rebased")
+	rebased := repo.commit("rebased", 100, 2000)
+	repo.git("checkout", "-q", "master")
+	last := repo.commit("last", 2500, 2500)
+
+	tests := []struct {
+		order    commitOrder
+		expected []string
+	}{
+		// Parents always come first, the ready commits are taken by their commit date
+		{orderTopological, []string{first, skewed, rebased, last}},
+		{orderCommitDate, []string{skewed, first, rebased, last}},
+		{orderAuthorDate, []string{rebased, first, last, skewed}},
+	}
+	for _, test := range tests {
+		commits := make([]*cachedCommit, 0)
+		for _, tip := range []string{last, rebased} {
+			reachable, err := reachableCommits(plumbing.NewHash(tip))
+			if err != nil {
+				t.Fatal(err)
+			}
+			commits = append(commits, reachable...)
+		}
+		commits = uniqueCommits(commits)
+
+		// Shuffle the input, the result must not depend on it
+		sort.Slice(commits, func(i, j int) bool { return commits[i].Hash > commits[j].Hash })
+		sortCommits(commits, test.order)
+		if hashes := cachedHashes(commits); !reflect.DeepEqual(hashes, test.expected) {
+			t.Errorf("sortCommits(%d) = %v, expected %v", test.order, hashes, test.expected)
+		}
+	}
+}
+
+// Remove the commits that are in the list more than once
+func uniqueCommits(commits []*cachedCommit) []*cachedCommit {
+	seen := make(map[string]bool)
+	unique := make([]*cachedCommit, 0, len(commits))
+	for _, c := range commits {
+		if !seen[c.Hash] {
+			seen[c.Hash] = true
+			unique = append(unique, c)
+		}
+	}
+	return unique
+}
+
+func TestBrokenCommitCacheIsRebuilt(t *testing.T) {
+	defer enterTempDir(t)()
+	repo := newTestRepo(t, getGitDir(), false)
+	first := repo.commit("first", 1000, 1000)
+
+	// A crash while writing the cache would leave the file cut off
+	if err := ioutil.WriteFile(commitCacheFile, []byte(`[{"Hash":"`+first[:10]), 0777); err != nil {
+		t.Fatal(err)
+	}
+	loadCommitCache()
+	if err := updateCommitCache(jobLog("test")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := commitCache[first]; !ok {
+		t.Fatal("the commit was not added to the rebuilt cache")
+	}
+
+	// The rebuilt cache was saved and can be loaded again
+	resetCommitCache()
+	loadCommitCache()
+	if _, ok := commitCache[first]; !ok {
+		t.Error("the saved cache doesn't contain the commit")
+	}
+	if _, err := os.Stat(commitCacheFile + ".tmp"); !os.IsNotExist(err) {
+		t.Error("the temporary file of the cache was not removed")
+	}
+}
@@ -2,11 +2,16 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
 	gitobject "github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/sirupsen/logrus"
 	"io/ioutil"
-	"log"
-	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -18,131 +23,602 @@ import (
 var (
 	pullMutex sync.Mutex
 	pullTime time.Time
+	// Unlike pullTime this is only set when everything worked
+	lastSuccessfulPull time.Time
 )
 
 // Clone the repo if it doesn't exist of download it if it does.
-func cloneIfNotExist() error {
+func cloneIfNotExist(log *logrus.Entry) error {
 	// Check if the repo already exists
 	if _, err := os.Stat(getGitDir()); err == nil {
-		log.Println("Repository already exists.")
+		log.Info("Repository already exists")
 		return nil
 	}
 
+	auth, err := gitAuth()
+	if err != nil {
+		return err
+	}
+
 	// Since it doesn't exist, we will clone it now
-	log.Println("Clone repository...")
-	_, err := git.PlainClone(getGitDir(), false, &git.CloneOptions{
+	log.Info("Clone repository...")
+	start := time.Now()
+	_, err = git.PlainClone(getGitDir(), false, &git.CloneOptions{
 		URL:               os.Getenv("GIT_URL"),
+		Auth:              auth,
 		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
 	})
 
 	if err != nil {
 		return err
 	}
-	log.Println("Done")
+	log.WithField("duration_ms", time.Since(start).Milliseconds()).Info("Cloned repository")
 	return nil
 }
 
-// Pull the repo from the origin
-func pull() error {
+// The kinds of changes to branches and tags the crawler can detect
+type refChangeKind int
+
+const (
+	branchCreated refChangeKind = iota
+	branchUpdated
+	// The branch was force-pushed, so its old commit is no ancestor of the new one
+	branchRewritten
+	branchDeleted
+	tagCreated
+	tagMoved
+	tagDeleted
+)
+
+// A change of a branch or tag on the remote, that got detected while pulling
+type refChange struct {
+	Kind refChangeKind
+	// The short name of the branch or tag, like master or abgabe-1
+	Name string
+	Old  plumbing.Hash
+	New  plumbing.Hash
+}
+
+// Pull all branches and tags from the origin and update the current branch
+// The returned changes are all branches and tags that got created, updated or deleted with this pull.
+func pull(log *logrus.Entry) ([]refChange, error) {
 	// Lock the Mutex
 	pullMutex.Lock()
 	defer pullMutex.Unlock()
 
+	start := time.Now()
+	changes, err := fetchOrigin(log)
+	pullDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		pullFailures.Inc()
+		return nil, err
+	}
+
+	lastSuccessfulPull = time.Now()
+	lastSuccessfulPullTimestamp.Set(float64(lastSuccessfulPull.Unix()))
+	return changes, nil
+}
+
+// The work of pull, the caller must hold the pullMutex
+func fetchOrigin(log *logrus.Entry) ([]refChange, error) {
 	// Open the repo
 	r, err := git.PlainOpen(getGitDir())
 	if err != nil {
-		return err
+		return nil, err
+	}
+	remote, err := r.Remote("origin")
+	if err != nil {
+		return nil, err
 	}
 
-	// Get the working directory for the repository
-	w, err := r.Worktree()
+	auth, err := gitAuth()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Remember the refs so we can later find out what changed
+	before, err := trackedRefs(r)
+	if err != nil {
+		return nil, err
 	}
 
-	// Pull the latest changes from the origin remote and merge into the current branch
-	err = w.Pull(&git.PullOptions{RemoteName: "origin"})
+	// go-git cannot prune while fetching, so we need to ask the origin which branches and tags still exist
+	advertised, err := remote.List(&git.ListOptions{Auth: auth})
 	pullTime = time.Now()
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[plumbing.ReferenceName]bool, len(advertised))
+	for _, ref := range advertised {
+		if ref.Name().IsBranch() {
+			exists[plumbing.NewRemoteReferenceName("origin", ref.Name().Short())] = true
+		} else {
+			exists[ref.Name()] = true
+		}
+	}
+
+	// Fetch all branches and tags, tags can be moved so they are always overwritten
+	start := time.Now()
+	err = remote.Fetch(&git.FetchOptions{
+		RefSpecs: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+		Tags:     git.AllTags,
+		Force:    true,
+		Auth:     auth,
+	})
 	if err != nil && err != git.NoErrAlreadyUpToDate {
-		return err
+		return nil, err
 	}
-	return nil
-}
 
-// The list is chronocally sorted with the newest commits as last
-func history() ([]gitobject.Commit, error) {
-	// Open the repo
-	r, err := git.PlainOpen(getGitDir())
+	// Remove the branches and tags that no longer exist on the origin
+	for name := range before {
+		if !exists[name] {
+			err = r.Storer.RemoveReference(name)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	after, err := trackedRefs(r)
+	if err != nil {
+		return nil, err
+	}
+	changes := diffRefs(before, after)
+	log.WithFields(logrus.Fields{
+		"duration_ms": time.Since(start).Milliseconds(),
+		"ref_changes": len(changes),
+	}).Debug("Fetched the origin")
+
+	// Find the branches where the history was rewritten
+	for i, change := range changes {
+		if change.Kind != branchUpdated {
+			continue
+		}
+		ancestor, err := isAncestor(r, change.Old, change.New)
+		if err != nil {
+			return nil, err
+		}
+		if !ancestor {
+			changes[i].Kind = branchRewritten
+		}
+	}
+
+	// Index the new commits now, so the next history query doesn't have to
+	err = updateCommitCache(log)
+	if err != nil {
+		log.WithError(err).Warn("Unable to update the commit cache")
+	}
+
+	// Update the current branch to the state of the origin
+	head, err := r.Head()
 	if err != nil {
 		return nil, err
 	}
+	hash, ok := after[plumbing.NewRemoteReferenceName("origin", head.Name().Short())]
+	if !ok || hash == head.Hash() {
+		return changes, nil
+	}
 
-	// Get the HEAD
-	cIter, err := r.Log(&git.LogOptions{})
+	// A normal pull would fail if the history was rewritten, but we only mirror the origin so we can just reset to
+	// it. The local branch is compared directly, because it might be behind the remote branch from an earlier
+	// failed pull.
+	ancestor, err := isAncestor(r, head.Hash(), hash)
 	if err != nil {
 		return nil, err
 	}
+	if !ancestor {
+		changes = markRewritten(changes, head.Name().Short(), head.Hash(), hash)
+	}
 
-	// Make a list of commits
-	var commits []gitobject.Commit
-	err = cIter.ForEach(func(c *gitobject.Commit) error {
-		commits = append(commits, *c)
+	w, err := r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	err = w.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset})
+	if err != nil {
+		return nil, err
+	}
+	log.WithFields(logrus.Fields{"from": head.Hash().String(), "to": hash.String()}).Info("Updated the current branch")
+
+	return changes, nil
+}
+
+// Make sure the changes contain a rewrite of the branch from the commit old to the commit new
+func markRewritten(changes []refChange, branch string, old, new plumbing.Hash) []refChange {
+	for i, change := range changes {
+		if change.Name == branch && (change.Kind == branchUpdated || change.Kind == branchRewritten) {
+			changes[i] = refChange{Kind: branchRewritten, Name: branch, Old: old, New: new}
+			return changes
+		}
+	}
+	return append(changes, refChange{Kind: branchRewritten, Name: branch, Old: old, New: new})
+}
+
+// Get all branches of the origin and all tags
+func trackedRefs(r *git.Repository) (map[plumbing.ReferenceName]plumbing.Hash, error) {
+	iter, err := r.References()
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[plumbing.ReferenceName]plumbing.Hash)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		// Skip symbolic references like origin/HEAD, they only point to a branch we track anyway
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		if ref.Name().IsTag() || strings.HasPrefix(ref.Name().String(), "refs/remotes/origin/") {
+			refs[ref.Name()] = ref.Hash()
+		}
 		return nil
 	})
+	return refs, err
+}
+
+// Compare two snapshots of trackedRefs
+func diffRefs(before, after map[plumbing.ReferenceName]plumbing.Hash) []refChange {
+	changes := make([]refChange, 0)
+	for name, hash := range after {
+		old, existed := before[name]
+		switch {
+		case !existed && name.IsTag():
+			changes = append(changes, refChange{Kind: tagCreated, Name: name.Short(), New: hash})
+		case !existed:
+			changes = append(changes, refChange{Kind: branchCreated, Name: branchName(name), New: hash})
+		case old == hash:
+			continue
+		case name.IsTag():
+			changes = append(changes, refChange{Kind: tagMoved, Name: name.Short(), Old: old, New: hash})
+		default:
+			changes = append(changes, refChange{Kind: branchUpdated, Name: branchName(name), Old: old, New: hash})
+		}
+	}
+
+	for name, hash := range before {
+		if _, ok := after[name]; ok {
+			continue
+		}
+		if name.IsTag() {
+			changes = append(changes, refChange{Kind: tagDeleted, Name: name.Short(), Old: hash})
+		} else {
+			changes = append(changes, refChange{Kind: branchDeleted, Name: branchName(name), Old: hash})
+		}
+	}
+
+	// Maps have no order, but the messages should always come in the same order
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return changes[i].Kind < changes[j].Kind
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// Get the name of a branch from the name of the remote reference, e.g. refs/remotes/origin/master is master
+func branchName(name plumbing.ReferenceName) string {
+	return strings.TrimPrefix(name.String(), "refs/remotes/origin/")
+}
+
+// Returns true if the commit old is an ancestor of the commit new (or the same commit)
+func isAncestor(r *git.Repository, old, new plumbing.Hash) (bool, error) {
+	oldCommit, err := r.CommitObject(old)
+	if err != nil {
+		return false, err
+	}
+	newCommit, err := r.CommitObject(new)
+	if err != nil {
+		return false, err
+	}
+	return oldCommit.IsAncestor(newCommit)
+}
+
+// Get the commit a branch or tag points to, annotated tags are resolved to the commit they tag
+func refCommit(hash plumbing.Hash) (*gitobject.Commit, error) {
+	r, err := git.PlainOpen(getGitDir())
 	if err != nil {
 		return nil, err
 	}
+	return repoRefCommit(r, hash)
+}
 
-	// Sort the commits
-	sort.Slice(commits, func(i, j int) bool {
-		return commits[i].Author.When.Local().Unix() < commits[j].Author.When.Local().Unix()
-	})
+// Like refCommit, but with a repository that is already open
+func repoRefCommit(r *git.Repository, hash plumbing.Hash) (*gitobject.Commit, error) {
+	if tag, err := r.TagObject(hash); err == nil {
+		return tag.Commit()
+	}
+	return r.CommitObject(hash)
+}
+
+// The list is chronocally sorted with the newest commits as last
+func history() ([]gitobject.Commit, error) {
+	head, err := getCurrentCommit()
+	if err != nil {
+		return nil, err
+	}
+	return historyFrom(plumbing.NewHash(head))
+}
 
-	return commits, nil
+// Get all commits reachable from the commit from
+// The list is chronocally sorted with the newest commits as last
+func historyFrom(from plumbing.Hash) ([]gitobject.Commit, error) {
+	return historyRange(plumbing.ZeroHash, from)
 }
 
 // Get all commits between two commits
 // Since is not included, until however is
 func historyBetween(since string, until string) ([]gitobject.Commit, error) {
-	all, err := history()
+	return historyRange(plumbing.NewHash(since), plumbing.NewHash(until))
+}
+
+// Get all commits reachable from until, that are not reachable from since (like git log since..until).
+// This also works if since is not an ancestor of until, e.g. after the history was rewritten.
+// The list is chronocally sorted with the newest commits as last
+func historyRange(since, until plumbing.Hash) ([]gitobject.Commit, error) {
+	cached, err := cachedRange(since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	sortCommits(cached, orderTopological)
+	return loadCommits(cached)
+}
+
+// Get all commits since a past commit (given with since which is a commit hash)
+func historySince(since string) ([]gitobject.Commit, error) {
+	curr, err := getCurrentCommit()
 	if err != nil {
 		return nil, err
 	}
+	return historyBetween(since, curr)
+}
 
-	// Filter the commits
-	var between []gitobject.Commit
-	sawSince := false
-	for _, c := range all {
-		if sawSince {
-			between = append(between, c)
+// Get all commits that changed the file at path (or a file in the directory at path), renames of files are followed
+// The list is chronocally sorted with the newest commits as last
+func fileHistory(path string) ([]gitobject.Commit, error) {
+	commits, _, err := fileHistoryWithPaths(path)
+	return commits, err
+}
+
+// Like fileHistory, but additionally returns the path the file had in each of the commits
+func fileHistoryWithPaths(path string) ([]gitobject.Commit, []string, error) {
+	path = treePath(filepath.Clean("/" + path))
+	err := checkPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	head, err := getCurrentCommit()
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := reachableCommits(plumbing.NewHash(head))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Go from the newest to the oldest commit, so that we know the old name once we reach the rename
+	sortCommits(all, orderTopological)
+	cached := make([]*cachedCommit, 0)
+	paths := make([]string, 0)
+	for i := len(all) - 1; i >= 0; i-- {
+		c := all[i]
+		if !c.touches(path) {
+			continue
+		}
+
+		cached = append(cached, c)
+		paths = append(paths, path)
+		if old, ok := c.Renames[path]; ok {
+			path = old
 		}
+	}
+
+	// Reverse the lists again, so the newest commit is the last one
+	for i, j := 0, len(cached)-1; i < j; i, j = i+1, j-1 {
+		cached[i], cached[j] = cached[j], cached[i]
+		paths[i], paths[j] = paths[j], paths[i]
+	}
+	commits, err := loadCommits(cached)
+	return commits, paths, err
+}
+
+// The lines of a file one author wrote
+type blameAuthor struct {
+	Author string
+	Lines  int
+	// When the author last changed one of the lines
+	Last time.Time
+}
+
+// Summarise who wrote the lines of the text file at path in the current commit.
+// The authors with the most lines come first, the second value is the number of lines in the file.
+func blameFile(path string) ([]blameAuthor, int, error) {
+	commits, paths, err := fileHistoryWithPaths(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(commits) == 0 {
+		return nil, 0, errors.New("the file has no history")
+	}
 
-		if c.Hash.String() == since {
-			sawSince = true
+	// Replay the history of the file, each line belongs to the commit that inserted it. The unchanged lines keep
+	// the author they had in the version before.
+	type blameLine struct {
+		Author string
+		When   time.Time
+	}
+	var lines []blameLine
+	previous := ""
+	for i, commit := range commits {
+		content := ""
+		file, err := commit.File(paths[i])
+		if err == nil {
+			binary, err := file.IsBinary()
+			if err != nil {
+				return nil, 0, err
+			}
+			if binary {
+				return nil, 0, errors.New("blame only works for text files")
+			}
+
+			content, err = file.Contents()
+			if err != nil {
+				return nil, 0, err
+			}
 		}
 
-		if c.Hash.String() == until {
-			break
+		current := make([]blameLine, 0, len(lines))
+		old := 0
+		for _, d := range diff.Do(previous, content) {
+			n := countLines(d.Text)
+			switch d.Type {
+			case diffmatchpatch.DiffEqual:
+				current = append(current, lines[old:old+n]...)
+				old += n
+			case diffmatchpatch.DiffDelete:
+				old += n
+			case diffmatchpatch.DiffInsert:
+				for j := 0; j < n; j++ {
+					current = append(current, blameLine{Author: commit.Author.Email, When: commit.Author.When})
+				}
+			}
 		}
+		lines = current
+		previous = content
+	}
 
+	authors := make(map[string]*blameAuthor)
+	for _, line := range lines {
+		author, ok := authors[line.Author]
+		if !ok {
+			author = &blameAuthor{Author: line.Author}
+			authors[line.Author] = author
+		}
+		author.Lines++
+		if line.When.After(author.Last) {
+			author.Last = line.When
+		}
 	}
 
-	return between, nil
+	summary := make([]blameAuthor, 0, len(authors))
+	for _, author := range authors {
+		summary = append(summary, *author)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Lines != summary[j].Lines {
+			return summary[i].Lines > summary[j].Lines
+		}
+		return summary[i].Author < summary[j].Author
+	})
+	return summary, len(lines), nil
 }
 
-// Get all commits since a past commit (given with since which is a commit hash)
-func historySince(since string) ([]gitobject.Commit, error) {
-	curr, err := getCurrentCommit()
+// Count the lines of a text, the last line doesn't need to end with a newline
+func countLines(text string) int {
+	n := strings.Count(text, "\n")
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		n++
+	}
+	return n
+}
+
+// Split an argument of the form path@revision into its parts, the revision is empty if there is none
+func splitRevision(arg string) (string, string) {
+	arg = strings.TrimSpace(arg)
+	i := strings.LastIndex(arg, "@")
+	if i < 0 {
+		return arg, ""
+	}
+	return arg[:i], arg[i+1:]
+}
+
+// Find the commit a revision points to. This can be a branch, a tag, a (abbreviated) hash or something like HEAD~3.
+func resolveCommit(r *git.Repository, rev string) (*gitobject.Commit, error) {
+	hash, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err == nil {
+		return r.CommitObject(*hash)
+	}
+
+	// Only the checked out branch exists locally, all other branches are only fetched as remote branches
+	ref, err := r.Reference(plumbing.NewRemoteReferenceName("origin", rev), true)
+	if err == nil {
+		return r.CommitObject(ref.Hash())
+	}
+
+	// go-git can only resolve full hashes, so we have to look for abbreviated ones ourselves
+	rev = strings.ToLower(rev)
+	if len(rev) < 4 || len(rev) >= 40 || strings.Trim(rev, "0123456789abcdef") != "" {
+		return nil, fmt.Errorf("unknown revision %s", rev)
+	}
+
+	iter, err := r.CommitObjects()
 	if err != nil {
 		return nil, err
 	}
-	return historyBetween(since, curr)
+	var found *gitobject.Commit
+	err = iter.ForEach(func(c *gitobject.Commit) error {
+		if !strings.HasPrefix(c.Hash.String(), rev) {
+			return nil
+		}
+		if found != nil && found.Hash != c.Hash {
+			return fmt.Errorf("the abbreviated hash %s is ambiguous", rev)
+		}
+		found = c
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("unknown revision %s", rev)
+	}
+	return found, nil
+}
+
+// Get the root tree of the repository at a revision
+func treeAt(rev string) (*gitobject.Tree, *gitobject.Commit, error) {
+	r, err := git.PlainOpen(getGitDir())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commit, err := resolveCommit(r, rev)
+	if err != nil {
+		return nil, nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, nil, err
+	}
+	return tree, commit, nil
+}
+
+// Convert a cleaned path into the form used by git trees, which is relative and always uses slashes
+func treePath(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
 }
 
 // Get the username from the git URL (your username).
+// The matriculation number is GIT_USERNAME, or the user in an http(s) GIT_URL. The user of an ssh URL is
+// usually just git, so it is not used.
 func getGitUser() string {
-	url, _ := url.Parse(os.Getenv("GIT_URL"))
-	return url.User.Username()
+	if user := os.Getenv("GIT_USERNAME"); user != "" {
+		return user
+	}
+
+	endpoint, err := gitEndpoint()
+	if err != nil || (endpoint.Protocol != "http" && endpoint.Protocol != "https") {
+		return ""
+	}
+	return endpoint.User
+}
+
+// Returns true if the email address belongs to the user of the git URL, which is the case if it contains the
+// matriculation number
+func isOwnEmail(email string) bool {
+	return strings.Contains(email, getGitUser()) && getGitUser() != ""
 }
 
 func getGitDir() string {
@@ -160,48 +636,150 @@ func getCurrentCommit() (string, error) {
 	return ref.Hash().String(), nil
 }
 
+// Get the name of the checked out branch
+func getCurrentBranch() (string, error) {
+	r, err := git.PlainOpen(getGitDir())
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := r.Head()
+	if err != nil {
+		return "", err
+	}
+	return ref.Name().Short(), nil
+}
+
 func getPullTime() time.Time {
 	pullMutex.Lock()
 	defer pullMutex.Unlock()
 	return pullTime
 }
 
-func readFile(path string) ([]byte, error) {
-	path = filepath.Clean(path)
+// Get the time of the last pull that didn't fail, it is zero if there was none since the start
+func getLastSuccessfulPull() time.Time {
+	pullMutex.Lock()
+	defer pullMutex.Unlock()
+	return lastSuccessfulPull
+}
+
+// Read a file from the repository. If rev is empty the file is read from the working tree, otherwise it is read
+// from the commit the revision points to.
+func readFile(path string, rev string) ([]byte, error) {
+	path = filepath.Clean("/" + path)
 	err := checkPath(path)
 	if err != nil {
 		return nil, err
 	}
-	return ioutil.ReadFile(filepath.Join(getGitDir(), path))
+
+	if rev == "" {
+		return ioutil.ReadFile(filepath.Join(getGitDir(), path))
+	}
+
+	tree, _, err := treeAt(rev)
+	if err != nil {
+		return nil, err
+	}
+	file, err := tree.File(treePath(path))
+	if err != nil {
+		return nil, err
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(contents), nil
+}
+
+// A single entry of a directory listing
+type fileEntry struct {
+	Name  string
+	IsDir bool
 }
 
-func listFiles(path string) ([]string, error) {
+// List the entries of a directory, the directories come first and both groups are sorted by name.
+// Like with readFile the entries are read from the commit of rev if it isn't empty.
+func listFiles(path string, rev string) ([]fileEntry, error) {
 	path = filepath.Clean("/" + path)
 	err := checkPath(path)
 	if err != nil {
 		return nil, err
 	}
 
-	files, err := ioutil.ReadDir(filepath.Join(getGitDir(), path))
-	if err != nil {
-		log.Println(err)
-		return nil, err
+	var entries []fileEntry
+	if rev == "" {
+		files, err := ioutil.ReadDir(filepath.Join(getGitDir(), path))
+		if err != nil {
+			logger.WithError(err).Warn("Unable to read the directory")
+			return nil, err
+		}
+
+		entries = make([]fileEntry, 0, len(files))
+		for _, file := range files {
+			entries = append(entries, fileEntry{Name: file.Name(), IsDir: file.IsDir()})
+		}
+	} else {
+		tree, _, err := treeAt(rev)
+		if err != nil {
+			return nil, err
+		}
+		if treePath(path) != "" {
+			tree, err = tree.Tree(treePath(path))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		entries = make([]fileEntry, 0, len(tree.Entries))
+		for _, entry := range tree.Entries {
+			entries = append(entries, fileEntry{Name: entry.Name, IsDir: entry.Mode == filemode.Dir})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name < entries[j].Name
+		})
 	}
 
-	names := make([]string, 0, len(files))
-	for _, file := range files {
-		name := ""
-		if file.IsDir() {
-			name = "📁 "
-		} else {
-			name = "📄 "
+	// Hide the git directory, nobody can read it anyway
+	visible := entries[:0]
+	for _, entry := range entries {
+		if checkPath(entry.Name) == nil {
+			visible = append(visible, entry)
 		}
+	}
+
+	sort.SliceStable(visible, func(i, j int) bool {
+		return visible[i].IsDir && !visible[j].IsDir
+	})
+	return visible, nil
+}
 
-		name += file.Name()
-		names = append(names, name)
+// Returns true if the path is a directory, like with readFile the commit of rev is used if it isn't empty
+func isDirectory(path string, rev string) (bool, error) {
+	path = filepath.Clean("/" + path)
+	if err := checkPath(path); err != nil {
+		return false, err
 	}
 
-	return names, nil
+	if rev == "" {
+		info, err := os.Stat(filepath.Join(getGitDir(), path))
+		if err != nil {
+			return false, err
+		}
+		return info.IsDir(), nil
+	}
+
+	tree, _, err := treeAt(rev)
+	if err != nil {
+		return false, err
+	}
+	if treePath(path) == "" {
+		return true, nil
+	}
+	entry, err := tree.FindEntry(treePath(path))
+	if err != nil {
+		return false, err
+	}
+	return entry.Mode == filemode.Dir, nil
 }
 
 func listFilesRaw(path string) ([]string, error) {
@@ -213,7 +791,7 @@ func listFilesRaw(path string) ([]string, error) {
 
 	files, err := ioutil.ReadDir(filepath.Join(getGitDir(), path))
 	if err != nil {
-		log.Println(err)
+		logger.WithError(err).Warn("Unable to read the directory")
 		return nil, err
 	}
 
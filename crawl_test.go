@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	gitobject "github.com/go-git/go-git/v5/plumbing/object"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A repository for the tests, created with the git command line
+type testRepo struct {
+	t   *testing.T
+	dir string
+}
+
+// Run the tests in an empty temporary directory, because the bot keeps its repository and cache in data/.
+// The returned function restores the working directory and the global state.
+func enterTempDir(t *testing.T) func() {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "ep2-bot-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("data", 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	logger.SetOutput(ioutil.Discard)
+	resetCommitCache()
+	return func() {
+		resetCommitCache()
+		_ = os.Chdir(wd)
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// Forget all cached commits, so that the tests don't influence each other
+func resetCommitCache() {
+	commitCacheMutex.Lock()
+	defer commitCacheMutex.Unlock()
+	commitCache = make(map[string]*cachedCommit)
+	commitCacheSeen = make(map[plumbing.Hash]bool)
+	commitCacheGeneration++
+}
+
+// Create a repository in the directory, bare repositories are used as the origin
+func newTestRepo(t *testing.T, dir string, bare bool) testRepo {
+	t.Helper()
+	args := []string{"init", "-q"}
+	if bare {
+		args = append(args, "--bare")
+	}
+	r := testRepo{t: t, dir: dir}
+	r.run(nil, append(args, dir)...)
+	r.run(nil, "-C", dir, "symbolic-ref", "HEAD", "refs/heads/master")
+	return r
+}
+
+// Run a git command and return its trimmed output
+func (r testRepo) run(env []string, args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Run a git command in the repository
+func (r testRepo) git(args ...string) string {
+	r.t.Helper()
+	return r.run(nil, append([]string{"-C", r.dir}, args...)...)
+}
+
+// Git only accepts raw dates after 1973, so the dates of the tests are seconds after this time
+const testEpoch = 1600000000
+
+// Format a date for GIT_AUTHOR_DATE and GIT_COMMITTER_DATE
+func gitDate(seconds int64) string {
+	return fmt.Sprintf("%d +0000", testEpoch+seconds)
+}
+
+// Commit a new file with the dates given as seconds after testEpoch and return the hash of the commit
+func (r testRepo) commit(message string, authorDate, committerDate int64) string {
+	r.t.Helper()
+	name := strings.ReplaceAll(message, " ", "-") + ".txt"
+	if err := ioutil.WriteFile(filepath.Join(r.dir, name), []byte(message+"\n"), 0666); err != nil {
+		r.t.Fatal(err)
+	}
+	r.git("add", name)
+	env := []string{"GIT_AUTHOR_DATE=" + gitDate(authorDate), "GIT_COMMITTER_DATE=" + gitDate(committerDate)}
+	r.run(env, "-C", r.dir, "-c", "user.name=Tutor", "-c", "user.email=tutor@example.com",
+		"-c", "commit.gpgsign=false", "commit", "-q", "-m", message)
+	return r.git("rev-parse", "HEAD")
+}
+
+// The hashes of the commits in their order
+func commitHashes(commits []gitobject.Commit) []string {
+	hashes := make([]string, 0, len(commits))
+	for _, c := range commits {
+		hashes = append(hashes, c.Hash.String())
+	}
+	return hashes
+}
+
+func TestForcePushIsDetected(t *testing.T) {
+	defer enterTempDir(t)()
+
+	origin := newTestRepo(t, "origin.git", true)
+	work := newTestRepo(t, "work", false)
+	work.git("remote", "add", "origin", "../origin.git")
+	a := work.commit("a", 1000, 1000)
+	b := work.commit("b", 2000, 2000)
+	work.git("push", "-q", "origin", "master")
+
+	wd, _ := os.Getwd()
+	oldURL := os.Getenv("GIT_URL")
+	defer os.Setenv("GIT_URL", oldURL)
+	os.Setenv("GIT_URL", filepath.Join(wd, origin.dir))
+	if err := cloneIfNotExist(jobLog("test")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite the last commit and force-push it
+	work.git("reset", "-q", "--hard", a)
+	rewritten := work.commit("b rewritten", 2000, 3000)
+	work.git("push", "-q", "-f", "origin", "master")
+
+	changes, err := pull(jobLog("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []refChange{{Kind: branchRewritten, Name: "master", Old: plumbing.NewHash(b), New: plumbing.NewHash(rewritten)}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected the changes %v, got %v", expected, changes)
+	}
+
+	current, err := getCurrentCommit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != rewritten {
+		t.Errorf("expected the clone to be reset to %s, got %s", rewritten, current)
+	}
+
+	// The commit that was force-pushed away is still in the history ranges
+	removed, err := historyBetween(rewritten, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashes := commitHashes(removed); !reflect.DeepEqual(hashes, []string{b}) {
+		t.Errorf("expected the removed commits %v, got %v", []string{b}, hashes)
+	}
+	added, err := historyBetween(b, rewritten)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashes := commitHashes(added); !reflect.DeepEqual(hashes, []string{rewritten}) {
+		t.Errorf("expected the added commits %v, got %v", []string{rewritten}, hashes)
+	}
+}
+
+func TestIsAncestor(t *testing.T) {
+	defer enterTempDir(t)()
+
+	repo := newTestRepo(t, getGitDir(), false)
+	base := repo.commit("base", 1000, 1000)
+	repo.git("checkout", "-q", "-b", "feature")
+	feature := repo.commit("feature", 2000, 2000)
+	repo.git("checkout", "-q", "master")
+	master := repo.commit("master", 3000, 3000)
+
+	r, err := git.PlainOpen(getGitDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		old, new string
+		expected bool
+	}{
+		{base, feature, true},
+		{base, master, true},
+		{master, master, true},
+		{feature, master, false},
+		{master, feature, false},
+		{master, base, false},
+	}
+	for _, test := range tests {
+		ancestor, err := isAncestor(r, plumbing.NewHash(test.old), plumbing.NewHash(test.new))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ancestor != test.expected {
+			t.Errorf("isAncestor(%s, %s) = %v, expected %v", test.old[:7], test.new[:7], ancestor, test.expected)
+		}
+	}
+}
+
+func TestResolveRemoteBranch(t *testing.T) {
+	defer enterTempDir(t)()
+
+	origin := newTestRepo(t, "origin.git", true)
+	work := newTestRepo(t, "work", false)
+	work.git("remote", "add", "origin", "../origin.git")
+	master := work.commit("master", 1000, 1000)
+	work.git("checkout", "-q", "-b", "feature")
+	feature := work.commit("feature", 2000, 2000)
+	work.git("push", "-q", "origin", "master", "feature")
+
+	wd, _ := os.Getwd()
+	oldURL := os.Getenv("GIT_URL")
+	defer os.Setenv("GIT_URL", oldURL)
+	os.Setenv("GIT_URL", filepath.Join(wd, origin.dir))
+	if err := cloneIfNotExist(jobLog("test")); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := git.PlainOpen(getGitDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		rev, expected string
+	}{
+		{"master", master},
+		// Only the checked out branch exists locally
+		{"feature", feature},
+		{"origin/feature", feature},
+		{feature[:7], feature},
+	}
+	for _, test := range tests {
+		commit, err := resolveCommit(r, test.rev)
+		if err != nil {
+			t.Errorf("resolveCommit(%s): %v", test.rev, err)
+			continue
+		}
+		if commit.Hash.String() != test.expected {
+			t.Errorf("resolveCommit(%s) = %s, expected %s", test.rev, commit.Hash, test.expected)
+		}
+	}
+}
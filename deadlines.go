@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where a deadline came from
+const (
+	deadlineFromSheet = "sheet"
+	deadlineFromAdmin = "admin"
+)
+
+// A deadline of an exercise
+type deadline struct {
+	ID       int
+	Exercise int
+	Title    string
+	Due      time.Time
+	Source   string
+	// The exercise sheet the deadline was found in, only set if Source is deadlineFromSheet
+	File string `json:",omitempty"`
+	// Deadlines from sheets are only marked as removed, otherwise they would be found again in the sheet
+	Removed bool `json:",omitempty"`
+}
+
+var (
+	deadlines      = make([]*deadline, 0)
+	deadlineNextID = 1
+
+	deadlineMutex = sync.Mutex{}
+	deadlineFile  = path.Join("data", "deadlines.json")
+)
+
+// Load the deadlines from the disk.
+// This function should be called once
+func loadDeadlines() error {
+	deadlineMutex.Lock()
+	defer deadlineMutex.Unlock()
+
+	byteValue, err := ioutil.ReadFile(deadlineFile)
+	if err != nil {
+		logger.Info("The deadline file does not exist")
+		return nil
+	}
+
+	err = json.Unmarshal(byteValue, &deadlines)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deadlines {
+		if d.ID >= deadlineNextID {
+			deadlineNextID = d.ID + 1
+		}
+	}
+	return nil
+}
+
+// Save the deadlines to the disk
+// Note: the caller must lock the deadlineMutex to avoid race conditions
+func saveDeadlines() error {
+	byteValue, err := json.Marshal(deadlines)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(deadlineFile, byteValue, 0777)
+}
+
+// Get all deadlines sorted by their due date
+func getDeadlines() []deadline {
+	deadlineMutex.Lock()
+	defer deadlineMutex.Unlock()
+
+	result := make([]deadline, 0, len(deadlines))
+	for _, d := range deadlines {
+		if !d.Removed {
+			result = append(result, *d)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Due.Before(result[j].Due)
+	})
+	return result
+}
+
+// Get the deadlines of an exercise sorted by their due date
+func exerciseDeadlines(number int) []deadline {
+	result := make([]deadline, 0)
+	for _, d := range getDeadlines() {
+		if d.Exercise == number {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// Get the deadlines that are not over yet
+func upcomingDeadlines() []deadline {
+	result := make([]deadline, 0)
+	for _, d := range getDeadlines() {
+		if d.Due.After(time.Now()) {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// Add a deadline by hand, for example if it isn't in the exercise sheet
+func addDeadline(number int, title string, due time.Time) (deadline, error) {
+	deadlineMutex.Lock()
+	defer deadlineMutex.Unlock()
+
+	d := &deadline{
+		ID:       deadlineNextID,
+		Exercise: number,
+		Title:    title,
+		Due:      due,
+		Source:   deadlineFromAdmin,
+	}
+	deadlineNextID++
+	deadlines = append(deadlines, d)
+	return *d, saveDeadlines()
+}
+
+// Remove the deadline with the id, the bool is false if there is no such deadline
+func removeDeadline(id int) (bool, error) {
+	deadlineMutex.Lock()
+	defer deadlineMutex.Unlock()
+
+	for i, d := range deadlines {
+		if d.ID != id || d.Removed {
+			continue
+		}
+		if d.Source == deadlineFromSheet {
+			d.Removed = true
+		} else {
+			deadlines = append(deadlines[:i], deadlines[i+1:]...)
+		}
+		return true, saveDeadlines()
+	}
+	return false, nil
+}
+
+// Replace the deadlines found in an exercise sheet with the ones found in a new version of it.
+// Deadlines that didn't change keep their id, deadlines the admin added stay untouched.
+func setSheetDeadlines(number int, file string, dues []time.Time) error {
+	deadlineMutex.Lock()
+	defer deadlineMutex.Unlock()
+
+	kept := make([]*deadline, 0, len(deadlines))
+	known := make(map[int64]bool)
+	for _, d := range deadlines {
+		if d.Source != deadlineFromSheet || d.File != file {
+			kept = append(kept, d)
+			continue
+		}
+		for _, due := range dues {
+			if d.Due.Equal(due) && d.Exercise == number {
+				kept = append(kept, d)
+				known[due.Unix()] = true
+				break
+			}
+		}
+	}
+	changed := len(kept) != len(deadlines)
+	deadlines = kept
+
+	for _, due := range dues {
+		if known[due.Unix()] {
+			continue
+		}
+		deadlines = append(deadlines, &deadline{
+			ID:       deadlineNextID,
+			Exercise: number,
+			Title:    fmt.Sprintf("Exercise %d", number),
+			Due:      due,
+			Source:   deadlineFromSheet,
+			File:     file,
+		})
+		deadlineNextID++
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return saveDeadlines()
+}
+
+// Find the deadlines in the PDF of an exercise at the commit rev and store them
+func extractSheetDeadlines(number int, file string, rev string) ([]time.Time, error) {
+	if !strings.HasSuffix(strings.ToLower(file), ".pdf") {
+		return nil, nil
+	}
+
+	content, err := readFile(file, rev)
+	if err != nil {
+		return nil, err
+	}
+	text, err := pdfText(content)
+	if err != nil {
+		return nil, err
+	}
+
+	dues := findDeadlines(text)
+	return dues, setSheetDeadlines(number, file, dues)
+}
+
+// Read the deadlines of all exercise sheets in the working tree, so that sheets that were released before the bot
+// started also have their deadlines
+func scanSheetDeadlines() error {
+	exercises, err := exerciseCatalog()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range exercises {
+		for _, file := range e.Files {
+			_, err := extractSheetDeadlines(e.Number, file, "")
+			if err != nil {
+				logger.WithError(err).WithField("file", file).Warn("Unable to read the deadlines of the exercise sheet")
+			}
+		}
+	}
+	return nil
+}
+
+// Find the deadlines in the sheets of the announcements, so the announcements can show them
+func announceDeadlines(announcements []exerciseAnnouncement) {
+	for i, announcement := range announcements {
+		for _, file := range announcement.Files {
+			dues, err := extractSheetDeadlines(announcement.Number, file, announcement.To)
+			if err != nil {
+				logger.WithError(err).WithField("file", file).Warn("Unable to read the deadlines of the exercise sheet")
+				continue
+			}
+			announcements[i].Deadlines = append(announcements[i].Deadlines, dues...)
+		}
+	}
+}
+
+// Format the due date of a deadline for a message
+func formatDue(due time.Time) string {
+	return due.Local().Format("Mon 02.01.2006 15:04")
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	gitobject "github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An exercise sheet with all its files, like the PDF, a zip with the templates and the tests
+type exercise struct {
+	Number int
+	// The paths of the files relative to the repository
+	Files []string
+}
+
+// The directory with the exercises, set with EXERCISE_DIR
+func exerciseDir() string {
+	if dir := os.Getenv("EXERCISE_DIR"); dir != "" {
+		return strings.Trim(path.Clean("/"+dir), "/")
+	}
+	return "angabe"
+}
+
+// The pattern the paths of exercise files (relative to the exercise directory) must match, set with EXERCISE_PATTERN.
+// The first capture group must be the number of the exercise.
+func exercisePattern() (*regexp.Regexp, error) {
+	pattern := os.Getenv("EXERCISE_PATTERN")
+	if pattern == "" {
+		pattern = `Aufgabenblatt(\d+)`
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if re.NumSubexp() < 1 {
+		return nil, errors.New("EXERCISE_PATTERN needs a capture group for the number of the exercise")
+	}
+	return re, nil
+}
+
+// The extensions of the files that belong to an exercise, set with EXERCISE_EXTENSIONS as a comma separated list
+// like ".pdf,.zip,.java"
+func exerciseExtensions() []string {
+	value := os.Getenv("EXERCISE_EXTENSIONS")
+	if value == "" {
+		return []string{".pdf"}
+	}
+
+	extensions := make([]string, 0)
+	for _, extension := range strings.Split(value, ",") {
+		extension = strings.ToLower(strings.TrimSpace(extension))
+		if extension == "" {
+			continue
+		}
+		if !strings.HasPrefix(extension, ".") {
+			extension = "." + extension
+		}
+		extensions = append(extensions, extension)
+	}
+	return extensions
+}
+
+// Find out to which exercise a file belongs, the path is relative to the repository
+func exerciseNumber(file string) (int, bool) {
+	file = strings.TrimPrefix(path.Clean("/"+file), "/")
+	if checkPath(file) != nil {
+		return 0, false
+	}
+
+	dir := exerciseDir()
+	relative := file
+	if dir != "" {
+		if !strings.HasPrefix(file, dir+"/") {
+			return 0, false
+		}
+		relative = strings.TrimPrefix(file, dir+"/")
+	}
+
+	allowed := false
+	for _, extension := range exerciseExtensions() {
+		if strings.HasSuffix(strings.ToLower(relative), extension) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return 0, false
+	}
+
+	pattern, err := exercisePattern()
+	if err != nil {
+		return 0, false
+	}
+	match := pattern.FindStringSubmatch(relative)
+	if match == nil {
+		return 0, false
+	}
+	number, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
+
+// Get all exercises in the working tree, sorted by their number
+func exerciseCatalog() ([]exercise, error) {
+	root := filepath.Join(getGitDir(), exerciseDir())
+	byNumber := make(map[int]*exercise)
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(getGitDir(), file)
+		if err != nil {
+			return err
+		}
+		relative = filepath.ToSlash(relative)
+		if checkPath(relative) != nil {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		number, ok := exerciseNumber(relative)
+		if !ok {
+			return nil
+		}
+		if byNumber[number] == nil {
+			byNumber[number] = &exercise{Number: number}
+		}
+		byNumber[number].Files = append(byNumber[number].Files, relative)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exercises := make([]exercise, 0, len(byNumber))
+	for _, e := range byNumber {
+		sort.Strings(e.Files)
+		exercises = append(exercises, *e)
+	}
+	sort.Slice(exercises, func(i, j int) bool {
+		return exercises[i].Number < exercises[j].Number
+	})
+	return exercises, nil
+}
+
+// Get the exercise with the number, the bool is false if there is none
+func findExercise(number int) (exercise, bool, error) {
+	exercises, err := exerciseCatalog()
+	if err != nil {
+		return exercise{}, false, err
+	}
+	for _, e := range exercises {
+		if e.Number == number {
+			return e, true, nil
+		}
+	}
+	return exercise{}, false, nil
+}
+
+// An exercise sheet that got released or corrected between two commits
+type exerciseAnnouncement struct {
+	Number int
+	// True if the exercise had no files before, otherwise the sheet got corrected
+	Released bool
+	// The added or modified files of the exercise
+	Files []string
+	// The commits before and after the change
+	From string
+	To   string
+	// The deadlines found in the sheet
+	Deadlines []time.Time
+	// The changes of the text of corrected PDFs by their path, see diffPDFText
+	Diffs map[string]string
+}
+
+// Find the exercises whose files got added or modified between the commits from and to, sorted by their number
+func exerciseAnnouncements(from, to string) ([]exerciseAnnouncement, error) {
+	fromTree, fromCommit, err := treeAt(from)
+	if err != nil {
+		return nil, err
+	}
+	toTree, toCommit, err := treeAt(to)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := gitobject.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	byNumber := make(map[int]*exerciseAnnouncement)
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+		if action == merkletrie.Delete {
+			continue
+		}
+		number, ok := exerciseNumber(change.To.Name)
+		if !ok || isPrivatePath(change.To.Name) {
+			continue
+		}
+
+		if byNumber[number] == nil {
+			byNumber[number] = &exerciseAnnouncement{
+				Number:   number,
+				Released: true,
+				From:     fromCommit.Hash.String(),
+				To:       toCommit.Hash.String(),
+			}
+		}
+		byNumber[number].Files = append(byNumber[number].Files, change.To.Name)
+	}
+	if len(byNumber) == 0 {
+		return nil, nil
+	}
+
+	// An exercise is only new if none of its files existed before
+	err = fromTree.Files().ForEach(func(f *gitobject.File) error {
+		if number, ok := exerciseNumber(f.Name); ok && byNumber[number] != nil {
+			byNumber[number].Released = false
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	announcements := make([]exerciseAnnouncement, 0, len(byNumber))
+	for _, announcement := range byNumber {
+		sort.Strings(announcement.Files)
+		announcements = append(announcements, *announcement)
+	}
+	sort.Slice(announcements, func(i, j int) bool {
+		return announcements[i].Number < announcements[j].Number
+	})
+	return announcements, nil
+}
+
+// Compare the text of the corrected PDFs with their old versions, so the announcements can show what changed
+func diffSheets(announcements []exerciseAnnouncement) {
+	for i, announcement := range announcements {
+		for _, file := range announcement.Files {
+			if !strings.HasSuffix(strings.ToLower(file), ".pdf") {
+				continue
+			}
+
+			// New files have no old version to compare with
+			old, err := readFile(file, announcement.From)
+			if err != nil {
+				continue
+			}
+			new, err := readFile(file, announcement.To)
+			if err != nil {
+				logger.WithError(err).WithField("file", file).Warn("Unable to read the exercise sheet")
+				continue
+			}
+
+			changes, err := diffPDFText(old, new)
+			if err != nil {
+				logger.WithError(err).WithField("file", file).Warn("Unable to compare the exercise sheets")
+				continue
+			}
+			if announcements[i].Diffs == nil {
+				announcements[i].Diffs = make(map[string]string)
+			}
+			announcements[i].Diffs[file] = changes
+		}
+	}
+}
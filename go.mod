@@ -6,9 +6,12 @@ require (
 	github.com/go-git/go-git/v5 v5.1.0
 	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible
 	github.com/jasonlvhit/gocron v0.0.0-20200423141508-ab84337f7963
+	github.com/ledongthuc/pdf v0.0.0-20200323191019-23c5852adbd2
+	github.com/prometheus/client_golang v1.7.1
+	github.com/sergi/go-diff v1.1.0
+	github.com/sirupsen/logrus v1.6.0
 	github.com/technoweenie/multipartstreamer v1.0.1 // indirect
 	golang.org/x/crypto v0.0.0-20200510223506-06a226fb4e37 // indirect
 	golang.org/x/net v0.0.0-20200528225125-3c3fba18258b // indirect
-	golang.org/x/sys v0.0.0-20200523222454-059865788121 // indirect
 	gopkg.in/src-d/go-git.v4 v4.13.1
 )
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A parser finds the points in the text of a feedback file, ok is false if there are no points in the text
+type pointsParser func(text string) (points float64, max float64, ok bool)
+
+// The parsers that can be chosen with POINTS_PARSER, courses with other feedback files can add their own here
+var pointsParsers = map[string]func() (pointsParser, error){
+	"punkte": func() (pointsParser, error) {
+		return regexPointsParser(punkteRegex), nil
+	},
+	"regex": func() (pointsParser, error) {
+		if os.Getenv("POINTS_PATTERN") == "" {
+			return nil, errors.New("the regex parser needs POINTS_PATTERN")
+		}
+		re, err := regexp.Compile(os.Getenv("POINTS_PATTERN"))
+		if err != nil {
+			return nil, err
+		}
+		if re.NumSubexp() < 2 {
+			return nil, errors.New("POINTS_PATTERN needs two capture groups, for the points and the maximum")
+		}
+		return regexPointsParser(re), nil
+	},
+}
+
+var (
+	// Points like "Punkte: 8/10", "Punkte: 7,5 / 10" or "Points: 8 of 10"
+	punkteRegex = regexp.MustCompile(`(?i)(?:punkte|points)\s*:?\s*(\d+(?:[.,]\d+)?)\s*(?:/|von|of)\s*(\d+(?:[.,]\d+)?)`)
+
+	// Lines with the total, if a feedback file has points for every task and the total
+	totalRegex = regexp.MustCompile(`(?i)(gesamt|summe|total)`)
+
+	numberRegex = regexp.MustCompile(`\d+`)
+)
+
+// Create a parser from a regex with two capture groups for the points and the maximum. If the text has more than one
+// match, the one in a line with the total (like "Gesamt") is taken, otherwise the points of all matches are added.
+func regexPointsParser(re *regexp.Regexp) pointsParser {
+	return func(text string) (float64, float64, bool) {
+		var points, max float64
+		found := false
+		for _, line := range strings.Split(text, "\n") {
+			for _, match := range re.FindAllStringSubmatch(line, -1) {
+				p, err1 := strconv.ParseFloat(strings.Replace(match[1], ",", ".", 1), 64)
+				m, err2 := strconv.ParseFloat(strings.Replace(match[2], ",", ".", 1), 64)
+				if err1 != nil || err2 != nil {
+					continue
+				}
+				if totalRegex.MatchString(line) {
+					return p, m, true
+				}
+				points += p
+				max += m
+				found = true
+			}
+		}
+		return points, max, found
+	}
+}
+
+// The parser for the feedback files, set with POINTS_PARSER (default: punkte)
+func getPointsParser() (pointsParser, error) {
+	name := strings.ToLower(os.Getenv("POINTS_PARSER"))
+	if name == "" {
+		name = "punkte"
+	}
+	create, ok := pointsParsers[name]
+	if !ok {
+		return nil, fmt.Errorf("there is no points parser called %s", name)
+	}
+	return create()
+}
+
+// The points of an exercise
+type grade struct {
+	Exercise int
+	Points   float64
+	Max      float64
+	File     string
+	When     time.Time
+	// If the points got corrected, the points before the correction
+	Corrected []float64
+}
+
+// Find out to which exercise a feedback file belongs, with the exercise pattern or the first number in its name
+func feedbackExercise(file string) (int, bool) {
+	if pattern, err := exercisePattern(); err == nil {
+		if match := pattern.FindStringSubmatch(file); match != nil {
+			if number, err := strconv.Atoi(match[1]); err == nil {
+				return number, true
+			}
+		}
+	}
+
+	number := numberRegex.FindString(path.Base(file))
+	if number == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(number)
+	return n, err == nil
+}
+
+// Collect the points of every exercise from the feedback files (the files in the private paths) in the history.
+// If the points of an exercise change later, the newest ones count.
+func collectGrades() ([]grade, error) {
+	parse, err := getPointsParser()
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := getCurrentCommit()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := reachableCommits(plumbing.NewHash(head))
+	if err != nil {
+		return nil, err
+	}
+	sortCommits(commits, orderTopological)
+
+	byExercise := make(map[int]*grade)
+	for _, c := range commits {
+		for _, file := range c.Files {
+			if !isPrivatePath(file) {
+				continue
+			}
+			number, ok := feedbackExercise(file)
+			if !ok {
+				continue
+			}
+			// For renames Files also has the old name, which can't be read anymore
+			content, err := readFile(file, c.Hash)
+			if err != nil {
+				continue
+			}
+			points, max, ok := parse(string(content))
+			if !ok {
+				continue
+			}
+
+			g := byExercise[number]
+			if g == nil {
+				g = &grade{Exercise: number}
+				byExercise[number] = g
+			} else if g.Points != points || g.Max != max {
+				g.Corrected = append(g.Corrected, g.Points)
+			} else {
+				continue
+			}
+			g.Points, g.Max, g.File, g.When = points, max, file, c.CommitterWhen
+		}
+	}
+
+	grades := make([]grade, 0, len(byExercise))
+	for _, g := range byExercise {
+		grades = append(grades, *g)
+	}
+	sort.Slice(grades, func(i, j int) bool {
+		return grades[i].Exercise < grades[j].Exercise
+	})
+	return grades, nil
+}
+
+// Format a number of points without unnecessary decimals
+func formatPoints(points float64) string {
+	return strconv.FormatFloat(points, 'f', -1, 64)
+}
+
+// Create a table with the points of every exercise, the total and if the percentage went up or down compared to the
+// exercise before
+func formatGrades(grades []grade) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%-4s %11s %5s\n", "Ex", "Points", "%")
+
+	var total, totalMax, last float64
+	for i, g := range grades {
+		percent := 0.0
+		if g.Max > 0 {
+			percent = g.Points / g.Max * 100
+		}
+		trend := ""
+		switch {
+		case i == 0:
+		case percent > last:
+			trend = "↗"
+		case percent < last:
+			trend = "↘"
+		default:
+			trend = "→"
+		}
+		if len(g.Corrected) > 0 {
+			trend = strings.TrimSpace(trend + " (corrected)")
+		}
+		line := fmt.Sprintf("%-4d %11s %4.0f%% %s", g.Exercise, formatPoints(g.Points)+"/"+formatPoints(g.Max), percent, trend)
+		builder.WriteString(strings.TrimRight(line, " ") + "\n")
+
+		total += g.Points
+		totalMax += g.Max
+		last = percent
+	}
+
+	totalPercent := 0.0
+	if totalMax > 0 {
+		totalPercent = total / totalMax * 100
+	}
+	fmt.Fprintf(&builder, "%-4s %11s %4.0f%%\n", "Sum", formatPoints(total)+"/"+formatPoints(totalMax), totalPercent)
+	return strings.TrimRight(builder.String(), "\n")
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Telegram is long polled with a timeout of 60 seconds, so a poll older than this means polling got stuck
+const maxPollAge = 3 * time.Minute
+
+var (
+	startTime = time.Now()
+
+	lastPoll      time.Time
+	lastPollMutex = sync.Mutex{}
+)
+
+// The result of a single health check
+type healthCheck struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+}
+
+// Remember that polling Telegram for updates worked
+func recordPoll() {
+	lastPollMutex.Lock()
+	defer lastPollMutex.Unlock()
+	lastPoll = time.Now()
+}
+
+func getLastPoll() time.Time {
+	lastPollMutex.Lock()
+	defer lastPollMutex.Unlock()
+	return lastPoll
+}
+
+// How old the last successful pull may be before the bot counts as unhealthy, set with HEALTH_PULL_MAX_AGE.
+// The repository is pulled every 30 minutes so the default of 2 hours allows a few failed pulls.
+func maxPullAge() (time.Duration, error) {
+	if os.Getenv("HEALTH_PULL_MAX_AGE") == "" {
+		return 2 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(os.Getenv("HEALTH_PULL_MAX_AGE"))
+	if err != nil {
+		return 0, fmt.Errorf("HEALTH_PULL_MAX_AGE is not a duration like 2h: %w", err)
+	}
+	return age, nil
+}
+
+func checkRepository() healthCheck {
+	if _, err := git.PlainOpen(getGitDir()); err != nil {
+		return healthCheck{false, fmt.Sprintf("the repository is not cloned: %s", err.Error())}
+	}
+	return healthCheck{true, "the repository is cloned"}
+}
+
+// The bot is given the same time to do the first pull after the start, as it has between two pulls
+func checkPull() healthCheck {
+	maxAge, _ := maxPullAge()
+	last := getLastSuccessfulPull()
+	if last.IsZero() {
+		if time.Since(startTime) > maxAge {
+			return healthCheck{false, fmt.Sprintf("no successful pull since the start %s ago", time.Since(startTime).Round(time.Second))}
+		}
+		return healthCheck{true, "waiting for the first pull"}
+	}
+
+	age := time.Since(last)
+	if age > maxAge {
+		return healthCheck{false, fmt.Sprintf("the last successful pull was %s ago at %s", age.Round(time.Second), last.Format(time.RFC3339))}
+	}
+	return healthCheck{true, fmt.Sprintf("the last successful pull was %s ago at %s", age.Round(time.Second), last.Format(time.RFC3339))}
+}
+
+func checkPolling() healthCheck {
+	last := getLastPoll()
+	if last.IsZero() {
+		if time.Since(startTime) > maxPollAge {
+			return healthCheck{false, "Telegram was never polled successfully"}
+		}
+		return healthCheck{true, "waiting for the first poll"}
+	}
+
+	age := time.Since(last)
+	if age > maxPollAge {
+		return healthCheck{false, fmt.Sprintf("the last successful poll was %s ago", age.Round(time.Second))}
+	}
+	return healthCheck{true, fmt.Sprintf("the last successful poll was %s ago", age.Round(time.Second))}
+}
+
+// All state of the bot is stored in the data directory, so it must be writable
+func checkStorage() healthCheck {
+	file, err := ioutil.TempFile("data", ".healthcheck")
+	if err != nil {
+		return healthCheck{false, fmt.Sprintf("the data directory is not writable: %s", err.Error())}
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString("ok")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return healthCheck{false, fmt.Sprintf("the data directory is not writable: %s", err.Error())}
+	}
+	return healthCheck{true, "the data directory is writable"}
+}
+
+// Respond with the result of the checks, the status is 503 if one of them failed
+func writeHealth(w http.ResponseWriter, checks map[string]healthCheck) {
+	status := http.StatusOK
+	for _, check := range checks {
+		if !check.OK {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"ok":     status == http.StatusOK,
+		"checks": checks,
+	})
+}
+
+// The bot is healthy if it still does its work, if not restarting it might help
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, map[string]healthCheck{
+		"pull":    checkPull(),
+		"polling": checkPolling(),
+		"storage": checkStorage(),
+	})
+}
+
+// The bot is ready once it has the repository, pulled it once and the health checks pass
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	pull := checkPull()
+	if getLastSuccessfulPull().IsZero() {
+		pull = healthCheck{false, "waiting for the first pull"}
+	}
+
+	writeHealth(w, map[string]healthCheck{
+		"repository": checkRepository(),
+		"pull":       pull,
+		"polling":    checkPolling(),
+		"storage":    checkStorage(),
+	})
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"os"
+)
+
+// The routes of the optional HTTP server
+func httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler)
+	mux.HandleFunc("/calendar.ics", calendarHandler)
+	return mux
+}
+
+// Start the HTTP server for the metrics, health checks and the calendar if HTTP_ADDR (like :8080) is set, the bot works the same without it.
+// This function returns immediately, the server runs in the background.
+func startHTTPServer() {
+	addr := os.Getenv("HTTP_ADDR")
+	if addr == "" {
+		return
+	}
+
+	go func() {
+		logger.WithField("addr", addr).Info("Starting the HTTP server")
+		err := http.ListenAndServe(addr, httpHandler())
+		logger.WithError(err).Error("The HTTP server stopped")
+	}()
+}
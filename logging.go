@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/sirupsen/logrus"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The logger of the bot, configured with LOG_FORMAT (json or text) and LOG_LEVEL (like debug, info or warn)
+var logger = logrus.New()
+
+// The log and state of the update that is currently handled, stored by the update id
+type request struct {
+	Log    *logrus.Entry
+	Start  time.Time
+	Result string
+}
+
+var (
+	requests   = sync.Map{}
+	jobCounter uint64
+)
+
+// Configure the logger from the environment. All logs, including the ones of the standard library and the telegram
+// library, go through the logger so they are structured and have no secrets in them.
+func setupLogging() error {
+	loadSecrets()
+	logger.SetOutput(os.Stderr)
+
+	switch strings.ToLower(os.Getenv("LOG_FORMAT")) {
+	case "", "json":
+		logger.SetFormatter(redactingFormatter{&logrus.JSONFormatter{}})
+	case "text":
+		logger.SetFormatter(redactingFormatter{&logrus.TextFormatter{FullTimestamp: true}})
+	default:
+		return fmt.Errorf("unknown LOG_FORMAT %s, use json or text", os.Getenv("LOG_FORMAT"))
+	}
+
+	if os.Getenv("LOG_LEVEL") != "" {
+		level, err := logrus.ParseLevel(os.Getenv("LOG_LEVEL"))
+		if err != nil {
+			return err
+		}
+		logger.SetLevel(level)
+	}
+
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer())
+	return tgbotapi.SetLogger(logger.WithField("component", "telegram"))
+}
+
+// Create the log for an update, every log line while handling the update has the same request_id so they can be
+// found together.
+func startRequest(update *tgbotapi.Update, command string) *logrus.Entry {
+	fields := logrus.Fields{
+		"request_id": fmt.Sprintf("update-%d", update.UpdateID),
+		"command":    command,
+	}
+	if update.Message != nil {
+		fields["chat_id"] = update.Message.Chat.ID
+		fields["user_id"] = update.Message.From.ID
+	} else if update.CallbackQuery != nil {
+		fields["chat_id"] = update.CallbackQuery.Message.Chat.ID
+		fields["user_id"] = update.CallbackQuery.From.ID
+	}
+
+	entry := logger.WithFields(fields)
+	requests.Store(update.UpdateID, &request{Log: entry, Start: time.Now(), Result: "ok"})
+	return entry
+}
+
+// Log how long it took to handle the update and how it went
+func finishRequest(update *tgbotapi.Update, command string) {
+	value, ok := requests.Load(update.UpdateID)
+	if !ok {
+		return
+	}
+	requests.Delete(update.UpdateID)
+
+	r := value.(*request)
+	// Unknown commands are counted together, otherwise every typo would become its own metric
+	if r.Result == "unknown" {
+		command = "unknown"
+	}
+	commandsHandled.WithLabelValues(command, r.Result).Inc()
+	r.Log.WithFields(logrus.Fields{
+		"duration_ms": time.Since(r.Start).Milliseconds(),
+		"result":      r.Result,
+	}).Info("Handled update")
+}
+
+// Get the log of the update that is currently handled
+func requestLog(update *tgbotapi.Update) *logrus.Entry {
+	if value, ok := requests.Load(update.UpdateID); ok {
+		return value.(*request).Log
+	}
+	return logger.WithField("request_id", fmt.Sprintf("update-%d", update.UpdateID))
+}
+
+// Set the result that is logged once the update is handled, like error or forbidden
+func setRequestResult(update *tgbotapi.Update, result string) {
+	if value, ok := requests.Load(update.UpdateID); ok {
+		value.(*request).Result = result
+	}
+}
+
+// Create the log for a run of a background job
+func jobLog(job string) *logrus.Entry {
+	return logger.WithFields(logrus.Fields{
+		"request_id": fmt.Sprintf("%s-%d", job, atomic.AddUint64(&jobCounter, 1)),
+		"job":        job,
+	})
+}
@@ -1,36 +1,72 @@
 package main
 
 import (
-	"log"
 	"os"
 
 	"github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
 func main() {
+	// Setup the logger first, so that everything else can already use it
+	err := setupLogging()
+	if err != nil {
+		logger.WithError(err).Fatal("Unable to setup the logging")
+	}
+
 	// Check if all the right environment variables are set.
 	checkEnvironment()
 
+	// Start the metrics and health endpoints if they are enabled
+	startHTTPServer()
+
 	// Clone the repo if it does not exist
-	err := cloneIfNotExist()
+	// Errors are logged with Fatal, because a panic would print the message again without hiding the secrets
+	err = cloneIfNotExist(jobLog("clone"))
 	if err != nil {
-		log.Panic("Unable to download the repository: ", err.Error())
+		logger.WithError(err).Fatal("Unable to download the repository")
 	}
 
 	// Load the subscribed users into memory
 	err = loadUsers()
 	if err != nil {
-		log.Panic("Unable to load the user file: ", err.Error())
+		logger.WithError(err).Fatal("Unable to load the user file")
 	}
-	log.Println("Users loaded")
+	logger.Info("Users loaded")
+
+	// Load the commit cache and add the commits that are new since the last start
+	loadCommitCache()
+	err = updateCommitCache(jobLog("cache"))
+	if err != nil {
+		logger.WithError(err).Fatal("Unable to update the commit cache")
+	}
+	logger.Info("Commit cache loaded")
+
+	// Load the deadlines and read the ones of the exercise sheets that were released while the bot was offline
+	err = loadDeadlines()
+	if err != nil {
+		logger.WithError(err).Fatal("Unable to load the deadline file")
+	}
+	err = scanSheetDeadlines()
+	if err != nil {
+		logger.WithError(err).Warn("Unable to read the deadlines of the exercise sheets")
+	}
+	err = loadReminders()
+	if err != nil {
+		logger.WithError(err).Fatal("Unable to load the reminder file")
+	}
+	err = loadValidation()
+	if err != nil {
+		logger.WithError(err).Fatal("Unable to load the validation file")
+	}
+	logger.Info("Deadlines loaded")
 
 	// Setup the telegram repo
 	bot, err := tgbotapi.NewBotAPI(os.Getenv("TELEGRAM_TOKEN"))
 	if err != nil {
-		log.Panic(err)
+		logger.WithError(err).Fatal("Unable to connect to Telegram")
 	}
 
-	log.Printf("Authorized on account %s", bot.Self.UserName)
+	logger.WithField("account", bot.Self.UserName).Info("Authorized on Telegram")
 
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
@@ -39,8 +75,12 @@ func main() {
 	startBackgroundManager(bot)
 
 	// Handle the updates
-	updates, err := bot.GetUpdatesChan(u)
+	updates := make(chan tgbotapi.Update, 100)
+	go pollUpdates(bot, u, updates)
 	for update := range updates {
+		// Every goroutine needs its own copy of the update
+		update := update
+
 		// Handle the current update in a new go routine
 		if update.Message != nil {
 			go handleMessage(bot, &update)
@@ -60,20 +100,55 @@ func checkEnvironment() {
 	isOk := true
 	if os.Getenv("TELEGRAM_TOKEN") == "" {
 		isOk = false
-		log.Println("The TELEGRAM_TOKEN environment variable is not set.")
+		logger.Error("The TELEGRAM_TOKEN environment variable is not set.")
 	}
 	if os.Getenv("TELEGRAM_ADMIN") == "" {
 		isOk = false
-		log.Println("The TELEGRAM_ADMIN environment variable is not set.")
+		logger.Error("The TELEGRAM_ADMIN environment variable is not set.")
 	}
 	if os.Getenv("GIT_URL") == "" {
 		isOk = false
-		log.Println("The GIT_URL environment variable is not set.")
+		logger.Error("The GIT_URL environment variable is not set.")
+	} else if _, err := gitAuth(); err != nil {
+		isOk = false
+		logger.WithError(err).Error("The git authentication is not configured correctly.")
+	}
+	if _, err := maxPullAge(); err != nil {
+		isOk = false
+		logger.WithError(err).Error("The HEALTH_PULL_MAX_AGE environment variable is not valid.")
+	}
+	if _, err := exercisePattern(); err != nil {
+		isOk = false
+		logger.WithError(err).Error("The EXERCISE_PATTERN environment variable is not valid.")
+	}
+	if _, err := reminderOffsets(); err != nil {
+		isOk = false
+		logger.WithError(err).Error("The REMINDER_OFFSETS environment variable is not valid.")
+	}
+	if _, err := privatePaths(); err != nil {
+		isOk = false
+		logger.WithError(err).Error("The PRIVATE_PATHS environment variable is not valid.")
+	}
+	if _, err := getPointsParser(); err != nil {
+		isOk = false
+		logger.WithError(err).Error("The POINTS_PARSER or POINTS_PATTERN environment variable is not valid.")
+	}
+	if _, err := submissionDirFormat(); err != nil {
+		isOk = false
+		logger.WithError(err).Error("The SUBMISSION_DIR environment variable is not valid.")
+	}
+	if _, err := validationChecks(); err != nil {
+		isOk = false
+		logger.WithError(err).Error("The VALIDATE_CHECKS environment variable is not valid.")
+	}
+	if _, err := pullFailureThreshold(); err != nil {
+		isOk = false
+		logger.WithError(err).Error("The PULL_FAILURE_THRESHOLD environment variable is not valid.")
 	}
 
 	if isOk == false {
-		log.Println("You can find more information about how to configure the bot at:")
-		log.Println("https://github.com/flofriday/EP2-Bot")
+		logger.Info("You can find more information about how to configure the bot at:")
+		logger.Info("https://github.com/flofriday/EP2-Bot")
 		os.Exit(1)
 	}
 }
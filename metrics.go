@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The metrics are exported on /metrics if HTTP_ADDR is set
+var (
+	commandsHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ep2bot_commands_total",
+		Help: "Commands and button presses handled, by command and result.",
+	}, []string{"command", "result"})
+
+	messagesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ep2bot_messages_sent_total",
+		Help: "Messages and files successfully sent to Telegram.",
+	})
+	messagesFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ep2bot_messages_failed_total",
+		Help: "Messages and files Telegram did not accept.",
+	})
+
+	pullDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ep2bot_pull_duration_seconds",
+		Help:    "How long pulling the repository took.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	})
+	pullFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ep2bot_pull_failures_total",
+		Help: "Pulls of the repository that failed.",
+	})
+	lastSuccessfulPullTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ep2bot_last_successful_pull_timestamp_seconds",
+		Help: "Unix time of the last pull that worked.",
+	})
+	commitsDetected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ep2bot_commits_detected_total",
+		Help: "New commits found while pulling the repository.",
+	})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "ep2bot_subscribers",
+		Help: "Chats subscribed to the updates.",
+	}, func() float64 {
+		return float64(len(getUsers()))
+	})
+)
+
+// Send a message or file to Telegram and count if it worked
+func send(bot *tgbotapi.BotAPI, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	msg, err := bot.Send(c)
+	if err != nil {
+		messagesFailed.Inc()
+	} else {
+		messagesSent.Inc()
+	}
+	return msg, err
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// The number of paged results kept in memory, older results can no longer be paged through
+const maxPagedResults = 50
+
+// A long list of results, that is sent as one message per page
+type pagedResult struct {
+	Header string
+	Pages  [][]string
+}
+
+var (
+	pagedResults      = make(map[int]*pagedResult)
+	pagedResultsOrder = make([]int, 0, maxPagedResults)
+	pagedResultsNext  = 0
+	pagedResultsMutex = sync.Mutex{}
+)
+
+// Remember the result and return the id for the callback data of the buttons
+func storePagedResult(result *pagedResult) int {
+	pagedResultsMutex.Lock()
+	defer pagedResultsMutex.Unlock()
+
+	// Forget the oldest result if there are too many
+	if len(pagedResultsOrder) == maxPagedResults {
+		delete(pagedResults, pagedResultsOrder[0])
+		pagedResultsOrder = pagedResultsOrder[1:]
+	}
+
+	id := pagedResultsNext
+	pagedResultsNext++
+	pagedResults[id] = result
+	pagedResultsOrder = append(pagedResultsOrder, id)
+	return id
+}
+
+// Get a result stored with storePagedResult
+func getPagedResult(id int) (*pagedResult, bool) {
+	pagedResultsMutex.Lock()
+	defer pagedResultsMutex.Unlock()
+	result, ok := pagedResults[id]
+	return result, ok
+}
+
+// Split the items into pages with at most perPage items, a page also ends before the message would get longer than
+// Telegram allows
+func paginate(header string, items []string, perPage int) [][]string {
+	space := maxMessageLength - utf8.RuneCountInString(header) - 1
+
+	pages := make([][]string, 0)
+	page := make([]string, 0, perPage)
+	length := 0
+	for _, item := range items {
+		// A single item that is too long for a message is cut off
+		item = truncateText(item, space-3)
+		itemLength := utf8.RuneCountInString(item) + 1
+		if len(page) == perPage || (len(page) > 0 && length+itemLength > space) {
+			pages = append(pages, page)
+			page = make([]string, 0, perPage)
+			length = 0
+		}
+		page = append(page, item)
+		length += itemLength
+	}
+	return append(pages, page)
+}
+
+// Create the text and the keyboard for a page of the result
+func renderPage(id int, result *pagedResult, page int) (string, tgbotapi.InlineKeyboardMarkup) {
+	pages := len(result.Pages)
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+
+	text := result.Header + "\n" + strings.Join(result.Pages[page], "\n")
+
+	navigation := make([]tgbotapi.InlineKeyboardButton, 0, 2)
+	if page > 0 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("◀️", fmt.Sprintf("page %d %d", id, page-1)))
+	}
+	navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", page+1, pages), "page -1"))
+	if page < pages-1 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("▶️", fmt.Sprintf("page %d %d", id, page+1)))
+	}
+	return text, tgbotapi.NewInlineKeyboardMarkup(navigation)
+}
+
+// Send a list of results, if it doesn't fit on one page buttons to go through the pages are added
+func sendPagedTo(bot *tgbotapi.BotAPI, chatID int64, header string, items []string, perPage int) {
+	pages := paginate(header, items, perPage)
+	if len(pages) == 1 {
+		sendMessageTo(bot, chatID, header+"\n"+strings.Join(pages[0], "\n"))
+		return
+	}
+
+	result := &pagedResult{Header: header, Pages: pages}
+	id := storePagedResult(result)
+	text, keyboard := renderPage(id, result, 0)
+
+	msg := tgbotapi.NewMessage(chatID, hideSecrets(text))
+	msg.ParseMode = "Markdown"
+	msg.DisableWebPagePreview = true
+	msg.ReplyMarkup = keyboard
+	_, _ = send(bot, msg)
+}
+
+// Handle the buttons of paged results, returns false if the data wasn't a page callback
+func handlePageCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, data []string) bool {
+	if data[0] != "page" {
+		return false
+	}
+
+	// The button in the middle only shows the page number
+	fields := make([]string, 0)
+	if len(data) > 1 {
+		fields = strings.Fields(data[1])
+	}
+	if len(fields) < 2 {
+		_, _ = bot.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, ""))
+		return true
+	}
+
+	id, _ := strconv.Atoi(fields[0])
+	page, _ := strconv.Atoi(fields[1])
+	result, ok := getPagedResult(id)
+	if !ok {
+		_, _ = bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(query.ID, "These results expired, please search again."))
+		return true
+	}
+	_, _ = bot.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, ""))
+
+	text, keyboard := renderPage(id, result, page)
+	edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, hideSecrets(text))
+	edit.ParseMode = "Markdown"
+	edit.DisableWebPagePreview = true
+	edit.ReplyMarkup = &keyboard
+	_, _ = send(bot, edit)
+	return true
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/ledongthuc/pdf"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Extract the text of a PDF, every row of text becomes a line
+func pdfText(content []byte) (text string, err error) {
+	// The PDF library panics on some broken files, which must not crash the bot
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("unable to read the PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		// The text comes as single characters with their position, so the lines and spaces have to be recreated
+		var last pdf.Text
+		for j, t := range page.Content().Text {
+			switch {
+			case j == 0:
+			case math.Abs(t.Y-last.Y) > last.FontSize/2:
+				builder.WriteString("\n")
+			case t.X-(last.X+last.W) > last.FontSize/5 && t.S != " ":
+				builder.WriteString(" ")
+			}
+			builder.WriteString(t.S)
+			last = t
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String(), nil
+}
+
+var (
+	// Words that introduce the deadline in the exercise sheets, like "Abgabe: 12.05.2020, 23:55". They must start a
+	// word and "due" needs a colon, otherwise they would be found inside German words like "individuell".
+	deadlineKeywordRegex = regexp.MustCompile(`(?i)\b(abgabe|deadline|abzugeben|einzureichen|fällig|due:)`)
+
+	// German dates like 12.05.2020, 12.5.2020 or 12. Mai 2020 with an optional time like 23:55, 23.55 or 23:55 Uhr
+	germanDateRegex = regexp.MustCompile(`(?i)(\d{1,2})\.\s*(\d{1,2}\.|jänner|januar|februar|feber|märz|april|mai|juni|juli|august|september|oktober|november|dezember)\s*(\d{4})(?:\s*,?\s*(?:um|bis)?\s*(\d{1,2})[:.](\d{2}))?`)
+
+	germanMonths = map[string]time.Month{
+		"jänner": time.January, "januar": time.January, "februar": time.February, "feber": time.February,
+		"märz": time.March, "april": time.April, "mai": time.May, "juni": time.June, "juli": time.July,
+		"august": time.August, "september": time.September, "oktober": time.October, "november": time.November,
+		"dezember": time.December,
+	}
+)
+
+// How far after a keyword the date of the deadline may be
+const deadlineSearchWindow = 120
+
+// Find the deadlines in the text of an exercise sheet. Only dates shortly after a word like "Abgabe" count, so that
+// other dates on the sheet (like the release date) are ignored. Without a time the deadline is at the end of the day.
+func findDeadlines(text string) []time.Time {
+	found := make([]time.Time, 0)
+	seen := make(map[time.Time]bool)
+	for _, keyword := range deadlineKeywordRegex.FindAllStringIndex(text, -1) {
+		end := keyword[1] + deadlineSearchWindow
+		if end > len(text) {
+			end = len(text)
+		}
+		match := germanDateRegex.FindStringSubmatch(text[keyword[1]:end])
+		if match == nil {
+			continue
+		}
+
+		due, ok := parseGermanDate(match)
+		if !ok || seen[due] {
+			continue
+		}
+		seen[due] = true
+		found = append(found, due)
+	}
+	return found
+}
+
+// Convert a match of germanDateRegex into a time in the local timezone
+func parseGermanDate(match []string) (time.Time, bool) {
+	day, _ := strconv.Atoi(match[1])
+	year, _ := strconv.Atoi(match[3])
+
+	var month time.Month
+	if number, err := strconv.Atoi(strings.TrimSuffix(match[2], ".")); err == nil {
+		month = time.Month(number)
+	} else {
+		month = germanMonths[strings.ToLower(match[2])]
+	}
+
+	hour, minute := 23, 59
+	if match[4] != "" {
+		hour, _ = strconv.Atoi(match[4])
+		minute, _ = strconv.Atoi(match[5])
+	}
+
+	if month < time.January || month > time.December || day < 1 || day > 31 || hour > 23 || minute > 59 {
+		return time.Time{}, false
+	}
+	due := time.Date(year, month, day, hour, minute, 0, 0, time.Local)
+	// time.Date normalizes dates like the 31.02., those are no real dates
+	if due.Day() != day {
+		return time.Time{}, false
+	}
+	return due, true
+}
+
+const (
+	// The maximum length of the diff created by diffPDFText, this leaves room for the header of the message since
+	// Telegram messages can't be longer than maxMessageLength
+	maxPDFDiffLength = 3500
+	// Longer lines of the diff are cut off
+	maxPDFDiffLineLength = 200
+)
+
+// Normalize the text of a PDF for comparing, so that changes of the whitespace don't show up as changes
+func normalizePDFText(text string) string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Create a readable diff of the text of two PDFs, with removed lines starting with - and added ones with +.
+// An empty string is returned if the text is the same.
+func diffPDFText(old, new []byte) (string, error) {
+	oldText, err := pdfText(old)
+	if err != nil {
+		return "", err
+	}
+	newText, err := pdfText(new)
+	if err != nil {
+		return "", err
+	}
+
+	changed := make([]string, 0)
+	for _, d := range diff.Do(normalizePDFText(oldText), normalizePDFText(newText)) {
+		prefix := ""
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			prefix = "- "
+		case diffmatchpatch.DiffInsert:
+			prefix = "+ "
+		default:
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(d.Text, "\n"), "\n") {
+			changed = append(changed, prefix+truncateText(line, maxPDFDiffLineLength))
+		}
+	}
+	if len(changed) == 0 {
+		return "", nil
+	}
+
+	// Only keep as many lines as fit into the message
+	length := 0
+	for i, line := range changed {
+		length += utf8.RuneCountInString(line) + 1
+		if length > maxPDFDiffLength {
+			changed = append(changed[:i], fmt.Sprintf("... and %d more changed lines", len(changed)-i))
+			break
+		}
+	}
+	return strings.Join(changed, "\n"), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseGermanDate(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected time.Time
+		ok       bool
+	}{
+		{"12.05.2020", time.Date(2020, time.May, 12, 23, 59, 0, 0, time.Local), true},
+		{"1.4.2020", time.Date(2020, time.April, 1, 23, 59, 0, 0, time.Local), true},
+		{"12. 5. 2020", time.Date(2020, time.May, 12, 23, 59, 0, 0, time.Local), true},
+		{"12.05.2020, 23:55", time.Date(2020, time.May, 12, 23, 55, 0, 0, time.Local), true},
+		{"12.05.2020 um 14.00", time.Date(2020, time.May, 12, 14, 0, 0, 0, time.Local), true},
+		{"12.05.2020 bis 8:30 Uhr", time.Date(2020, time.May, 12, 8, 30, 0, 0, time.Local), true},
+		{"12. Mai 2020", time.Date(2020, time.May, 12, 23, 59, 0, 0, time.Local), true},
+		{"3. Jänner 2021", time.Date(2021, time.January, 3, 23, 59, 0, 0, time.Local), true},
+		{"28. Feber 2021", time.Date(2021, time.February, 28, 23, 59, 0, 0, time.Local), true},
+		{"1. MÄRZ 2021, 12:00", time.Date(2021, time.March, 1, 12, 0, 0, 0, time.Local), true},
+		{"31. Dezember 2020", time.Date(2020, time.December, 31, 23, 59, 0, 0, time.Local), true},
+		// Dates that don't exist
+		{"31.02.2020", time.Time{}, false},
+		{"12.13.2020", time.Time{}, false},
+		{"0.05.2020", time.Time{}, false},
+		{"12.05.2020 25:00", time.Time{}, false},
+		{"12.05.2020 12:60", time.Time{}, false},
+	}
+	for _, test := range tests {
+		match := germanDateRegex.FindStringSubmatch(test.text)
+		if match == nil {
+			t.Errorf("germanDateRegex doesn't match %q", test.text)
+			continue
+		}
+		due, ok := parseGermanDate(match)
+		if ok != test.ok || !due.Equal(test.expected) {
+			t.Errorf("parseGermanDate(%q) = %v, %v, expected %v, %v", test.text, due, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestFindDeadlines(t *testing.T) {
+	may12 := time.Date(2020, time.May, 12, 23, 55, 0, 0, time.Local)
+	may19 := time.Date(2020, time.May, 19, 23, 59, 0, 0, time.Local)
+
+	tests := []struct {
+		text     string
+		expected []time.Time
+	}{
+		{"Abgabe: 12.05.2020, 23:55", []time.Time{may12}},
+		{"Abgabetermin ist der 12. Mai 2020 um 23:55", []time.Time{may12}},
+		{"Deadline:\n12.05.2020 23:55 Uhr", []time.Time{may12}},
+		{"Die Lösung ist bis 12.05.2020, 23:55 abzugeben", []time.Time{}},
+		{"abzugeben bis 12.05.2020, 23:55", []time.Time{may12}},
+		{"Einzureichen bis 19.05.2020", []time.Time{may19}},
+		{"Fällig am 19.5.2020", []time.Time{may19}},
+		{"Due: 12.05.2020, 23:55", []time.Time{may12}},
+		// The same deadline is only found once, different ones are kept in the order of the text
+		{"Abgabe: 12.05.2020, 23:55\nNochmal, Abgabe: 12.05.2020, 23:55", []time.Time{may12}},
+		{"Abgabe Teil 1: 12.05.2020, 23:55\nAbgabe Teil 2: 19.05.2020", []time.Time{may12, may19}},
+		// Dates without a keyword before them are no deadlines
+		{"Ausgabe: 01.04.2020", []time.Time{}},
+		{"Ausgabe: 01.04.2020\nAbgabe: 12.05.2020, 23:55", []time.Time{may12}},
+		// The keywords are not found inside other words
+		{"Die Aufgaben werden individuell bewertet. Ausgabe: 01.04.2020", []time.Time{}},
+		{"Berechnen Sie die Residuen. Ausgabe: 01.04.2020", []time.Time{}},
+		{"Deadlines: none", []time.Time{}},
+		// The date must be shortly after the keyword
+		{"Abgabe im TUWEL." + string(make([]byte, deadlineSearchWindow)) + "12.05.2020", []time.Time{}},
+		// Dates that don't exist are skipped
+		{"Abgabe: 31.02.2020", []time.Time{}},
+	}
+	for _, test := range tests {
+		if deadlines := findDeadlines(test.text); !reflect.DeepEqual(deadlines, test.expected) {
+			t.Errorf("findDeadlines(%q) = %v, expected %v", test.text, deadlines, test.expected)
+		}
+	}
+}
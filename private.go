@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	gitobject "github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// The glob patterns of the private paths, set with PRIVATE_PATHS as a comma separated list like
+// "feedback/**,bewertung*". Changes of these files, like the feedback of the tutors, are only sent to the admin.
+func privatePaths() ([]string, error) {
+	patterns := make([]string, 0)
+	for _, pattern := range strings.Split(os.Getenv("PRIVATE_PATHS"), ",") {
+		pattern = strings.Trim(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("the private path %s is not a valid pattern", pattern)
+			}
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// Check if a path relative to the repository matches a glob pattern. Like in .gitignore, ** matches any number of
+// directories and a pattern without a slash matches a file or directory with that name anywhere in the repository.
+func matchPath(pattern, file string) bool {
+	segments := strings.Split(strings.Trim(path.Clean("/"+file), "/"), "/")
+	if !strings.Contains(pattern, "/") {
+		for _, segment := range segments {
+			if ok, _ := path.Match(pattern, segment); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return matchSegments(strings.Split(pattern, "/"), segments)
+}
+
+// Match the segments of a path against the segments of a pattern, the pattern may match only the beginning of the
+// path, so that the files in a matching directory match as well
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// Check if a file matches one of the private paths
+func isPrivatePath(file string) bool {
+	patterns, _ := privatePaths()
+	for _, pattern := range patterns {
+		if matchPath(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the private files a commit changed
+func privateCommitFiles(commit gitobject.Commit) ([]string, error) {
+	if patterns, _ := privatePaths(); len(patterns) == 0 {
+		return nil, nil
+	}
+
+	stats, err := commit.Stats()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0)
+	for _, stat := range stats {
+		if isPrivatePath(stat.Name) {
+			files = append(files, stat.Name)
+		}
+	}
+	return files, nil
+}
+
+// Check if a commit changed a private file. If the files of the commit can't be loaded the commit is treated as
+// private, because the commit message alone might already tell the grade.
+func isPrivateCommit(commit gitobject.Commit) bool {
+	files, err := privateCommitFiles(commit)
+	if err != nil {
+		logger.WithError(err).WithField("commit", commit.Hash.String()).Warn("Unable to load the files of the commit")
+		return true
+	}
+	return len(files) > 0
+}
+
+// Split the commits into the ones everybody can see and the ones that are only for the admin
+func splitPrivateCommits(commits []gitobject.Commit) ([]gitobject.Commit, []gitobject.Commit) {
+	public := make([]gitobject.Commit, 0, len(commits))
+	private := make([]gitobject.Commit, 0)
+	for _, c := range commits {
+		if isPrivateCommit(c) {
+			private = append(private, c)
+			continue
+		}
+		public = append(public, c)
+	}
+	return public, private
+}
+
+// Remove the private commits, for everyone except the admin in their private chat
+func filterPrivateCommits(commits []gitobject.Commit) []gitobject.Commit {
+	public, _ := splitPrivateCommits(commits)
+	return public
+}
+
+// Check if a cached commit changed a private file, this is cheaper than isPrivateCommit because the files are known
+func (c *cachedCommit) isPrivate() bool {
+	for _, file := range c.Files {
+		if isPrivatePath(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// Private commits are only shown to the admin and only in the private chat with the bot, so they don't end up in a
+// group chat
+func showsPrivate(userID int, chatID int64) bool {
+	return isAdmin(userID) && chatID == int64(getAdmin())
+}
+
+// Send the admin the private commits with the private files attached, as they are in the commit of rev
+func sendPrivateCommits(bot *tgbotapi.BotAPI, chatID int64, commits []gitobject.Commit, rev string) {
+	if len(commits) == 0 {
+		return
+	}
+
+	message := "🔒 *New private commits:*\n"
+	files := make(map[string]bool)
+	for _, commit := range commits {
+		message += formatCommit(commit)
+		changed, err := privateCommitFiles(commit)
+		if err != nil {
+			continue
+		}
+		for _, file := range changed {
+			files[file] = true
+		}
+	}
+	sendMessageTo(bot, chatID, message)
+
+	sorted := make([]string, 0, len(files))
+	for file := range files {
+		sorted = append(sorted, file)
+	}
+	sort.Strings(sorted)
+	for _, file := range sorted {
+		content, err := readFile(file, rev)
+		if err != nil {
+			// The file was deleted by a later commit
+			logger.WithError(err).WithField("file", file).Warn("Unable to read the private file")
+			continue
+		}
+		sendBytesTo(bot, chatID, path.Base(file), content)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, file string
+		expected      bool
+	}{
+		// A directory with ** matches the directory itself and everything in it
+		{"feedback/**", "feedback", true},
+		{"feedback/**", "feedback/", true},
+		{"feedback/**", "feedback/ue1.txt", true},
+		{"feedback/**", "feedback/ue1/punkte.txt", true},
+		{"feedback/**", "/feedback/ue1.txt", true},
+		{"feedback/**", "feedback2/x", false},
+		{"feedback/**", "feedbackx", false},
+		{"feedback/**", "old/feedback/ue1.txt", false},
+		{"**/feedback/**", "old/feedback/ue1.txt", true},
+		{"**/feedback/**", "feedback/ue1.txt", true},
+		{"**/feedback/**", "old/feedback2/ue1.txt", false},
+		{"ue*/**/punkte.txt", "ue1/feedback/a/punkte.txt", true},
+		{"ue*/**/punkte.txt", "ue1/punkte.txt", true},
+		{"ue*/**/punkte.txt", "ue1/punkte.txt.bak", false},
+		// A pattern without a slash matches a file or directory with that name anywhere
+		{"bewertung*", "bewertung.txt", true},
+		{"bewertung*", "ue1/bewertung_ue1.pdf", true},
+		{"bewertung*", "bewertungen/ue1.pdf", true},
+		{"bewertung*", "ue1/meine_bewertung.txt", false},
+		{"bewertung*", "ue1/Bewertung.txt", false},
+		{"*.csv", "a/b/punkte.csv", true},
+		{"*.csv", "a/punkte.csv.txt", false},
+		// A pattern with a slash but without ** is anchored at the root of the repository
+		{"ue1/feedback.txt", "ue1/feedback.txt", true},
+		{"ue1/feedback.txt", "x/ue1/feedback.txt", false},
+		{"ue1/feedback.txt", "ue1/feedback.txt2", false},
+		{"ue*/feedback.txt", "ue12/feedback.txt", true},
+		{"ue*/feedback.txt", "ue12/sub/feedback.txt", false},
+		// A pattern that matches a directory also matches the files in it
+		{"intern/tutoren", "intern/tutoren/liste.txt", true},
+		{"intern/tutoren", "intern/tutoren2/liste.txt", false},
+		{"intern/tutoren", "intern", false},
+	}
+	for _, test := range tests {
+		if matched := matchPath(test.pattern, test.file); matched != test.expected {
+			t.Errorf("matchPath(%q, %q) = %v, expected %v", test.pattern, test.file, matched, test.expected)
+		}
+	}
+}
+
+func TestPrivatePaths(t *testing.T) {
+	defer setEnv(t, map[string]string{"PRIVATE_PATHS": " /feedback/** , bewertung*,, "})()
+
+	patterns, err := privatePaths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"feedback/**", "bewertung*"}; !reflect.DeepEqual(patterns, expected) {
+		t.Errorf("expected the patterns %v, got %v", expected, patterns)
+	}
+
+	tests := map[string]bool{
+		"feedback/ue1.txt":      true,
+		"ue2/bewertung.pdf":     true,
+		"feedback2/x":           false,
+		"angabe/Aufgabenblatt1": false,
+	}
+	for file, expected := range tests {
+		if private := isPrivatePath(file); private != expected {
+			t.Errorf("isPrivatePath(%q) = %v, expected %v", file, private, expected)
+		}
+	}
+}
+
+func TestInvalidPrivatePaths(t *testing.T) {
+	defer setEnv(t, map[string]string{"PRIVATE_PATHS": "feedback/[ab"})()
+
+	if _, err := privatePaths(); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
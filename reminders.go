@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// The reminders that were already sent, so they are not sent again after a restart
+	sentReminders = make(map[string]bool)
+
+	reminderMutex = sync.Mutex{}
+	reminderFile  = path.Join("data", "reminders.json")
+)
+
+// Parse a duration like 2h30m, which can also be in days like 3d
+func parseReminderOffset(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("%s is not a number of days", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// How long before a deadline the subscribers get reminded, set with REMINDER_OFFSETS as a comma separated list.
+// The offsets are sorted with the largest first.
+func reminderOffsets() ([]time.Duration, error) {
+	value := os.Getenv("REMINDER_OFFSETS")
+	if value == "" {
+		value = "3d,1d,2h"
+	}
+
+	offsets := make([]time.Duration, 0)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		offset, err := parseReminderOffset(field)
+		if err != nil {
+			return nil, err
+		}
+		if offset <= 0 {
+			return nil, fmt.Errorf("the reminder offset %s must be positive", field)
+		}
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i] > offsets[j]
+	})
+	return offsets, nil
+}
+
+// Load the sent reminders from the disk.
+// This function should be called once
+func loadReminders() error {
+	reminderMutex.Lock()
+	defer reminderMutex.Unlock()
+
+	byteValue, err := ioutil.ReadFile(reminderFile)
+	if err != nil {
+		logger.Info("The reminder file does not exist")
+		return nil
+	}
+
+	return json.Unmarshal(byteValue, &sentReminders)
+}
+
+// Save the sent reminders to the disk
+// Note: the caller must lock the reminderMutex to avoid race conditions
+func saveReminders() error {
+	byteValue, err := json.Marshal(sentReminders)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(reminderFile, byteValue, 0777)
+}
+
+// The key of a reminder in sentReminders. The due date is part of it, so a moved deadline gets reminded again.
+func reminderKey(d deadline, offset time.Duration) string {
+	return fmt.Sprintf("%d/%d/%s", d.ID, d.Due.Unix(), offset)
+}
+
+// Find the deadlines that need a reminder now. If the bot was offline, or the deadline got added late, several
+// offsets might be due at once, but only one reminder is sent.
+// The returned keys must be marked as sent once the reminders were sent.
+func dueReminders(now time.Time) ([]deadline, []string, error) {
+	offsets, err := reminderOffsets()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reminderMutex.Lock()
+	defer reminderMutex.Unlock()
+
+	remind := make([]deadline, 0)
+	keys := make([]string, 0)
+	for _, d := range upcomingDeadlines() {
+		due := false
+		for _, offset := range offsets {
+			key := reminderKey(d, offset)
+			if sentReminders[key] || now.Before(d.Due.Add(-offset)) {
+				continue
+			}
+			due = true
+			keys = append(keys, key)
+		}
+		if due {
+			remind = append(remind, d)
+		}
+	}
+	return remind, keys, nil
+}
+
+// Remember that the reminders were sent, reminders of deadlines that are over are forgotten
+func markRemindersSent(keys []string) error {
+	reminderMutex.Lock()
+	defer reminderMutex.Unlock()
+
+	for _, key := range keys {
+		sentReminders[key] = true
+	}
+
+	upcoming := make(map[int]bool)
+	for _, d := range upcomingDeadlines() {
+		upcoming[d.ID] = true
+	}
+	for key := range sentReminders {
+		id, _ := strconv.Atoi(strings.SplitN(key, "/", 2)[0])
+		if !upcoming[id] {
+			delete(sentReminders, key)
+		}
+	}
+	return saveReminders()
+}
+
+// Format how long it is until the deadline, like "2 days and 3 hours"
+func formatRemaining(remaining time.Duration) string {
+	days := int(remaining.Hours()) / 24
+	hours := int(remaining.Hours()) % 24
+	minutes := int(remaining.Minutes()) % 60
+
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch {
+	case days > 0 && hours > 0:
+		return plural(days, "day") + " and " + plural(hours, "hour")
+	case days > 0:
+		return plural(days, "day")
+	case hours > 0:
+		return plural(hours, "hour")
+	default:
+		return plural(minutes, "minute")
+	}
+}
+
+// Send the reminders for the deadlines that are coming up to all subscribers.
+// This job runs every minute.
+func reminderJob(bot *tgbotapi.BotAPI) {
+	log := jobLog("reminder")
+	now := time.Now()
+	remind, keys, err := dueReminders(now)
+	if err != nil {
+		log.WithError(err).Error("Unable to find the due reminders")
+		return
+	}
+	if len(remind) == 0 {
+		return
+	}
+
+	for _, d := range remind {
+		message := fmt.Sprintf("⏰ *Reminder: %s is due in %s*\nDeadline: %s",
+			d.Title, formatRemaining(d.Due.Sub(now)), formatDue(d.Due))
+		for _, subscription := range getUsers() {
+			sendMessageTo(bot, subscription, message)
+		}
+	}
+
+	err = markRemindersSent(keys)
+	if err != nil {
+		log.WithError(err).Error("Unable to save the sent reminders")
+	}
+	log.WithField("reminders", len(remind)).Info("Sent the reminders")
+}
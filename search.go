@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	gitobject "github.com/go-git/go-git/v5/plumbing/object"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Files larger than this are not searched, they are most likely not source code
+const maxGrepFileSize = 1024 * 1024
+
+// Used to stop walking the files once enough matches are found
+var errGrepLimit = errors.New("too many matches")
+
+// A line in a file that matched the pattern of grepFiles
+type grepMatch struct {
+	Path string
+	Line int
+	Text string
+}
+
+// Search all text files in the directory dir of the working tree for lines matching the pattern.
+// At most limit matches are returned, the bool is true if there would have been more.
+func grepFiles(pattern *regexp.Regexp, dir string, limit int) ([]grepMatch, bool, error) {
+	dir = filepath.Clean("/" + dir)
+	err := checkPath(dir)
+	if err != nil {
+		return nil, false, err
+	}
+
+	root := filepath.Join(getGitDir(), dir)
+	matches := make([]grepMatch, 0)
+	truncated := false
+	err = filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(getGitDir(), file)
+		if err != nil {
+			return err
+		}
+
+		// Skip everything nobody is allowed to read
+		if checkPath(relative) != nil {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || info.Size() > maxGrepFileSize {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		// Binary files contain null bytes, text files usually don't
+		if bytes.IndexByte(content, 0) >= 0 {
+			return nil
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(make([]byte, 0, 64*1024), maxGrepFileSize)
+		for line := 1; scanner.Scan(); line++ {
+			if !pattern.MatchString(scanner.Text()) {
+				continue
+			}
+			if len(matches) == limit {
+				truncated = true
+				return errGrepLimit
+			}
+			matches = append(matches, grepMatch{Path: filepath.ToSlash(relative), Line: line, Text: scanner.Text()})
+		}
+		return nil
+	})
+	if err != nil && err != errGrepLimit {
+		return nil, false, err
+	}
+
+	return matches, truncated, nil
+}
+
+// Search the messages of all commits in the current history for the text, the case is ignored
+// The list is chronocally sorted with the newest commits as last
+func searchLog(text string) ([]gitobject.Commit, error) {
+	commits, err := history()
+	if err != nil {
+		return nil, err
+	}
+
+	text = strings.ToLower(text)
+	found := make([]gitobject.Commit, 0)
+	for _, c := range commits {
+		if strings.Contains(strings.ToLower(c.Message), text) {
+			found = append(found, c)
+		}
+	}
+	return found, nil
+}
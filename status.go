@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
+	"os"
+	"strings"
+	"time"
+)
+
+// The directory in which the solution of an exercise is submitted, set with SUBMISSION_DIR where %d is replaced by
+// the number of the exercise
+func submissionDirFormat() (string, error) {
+	format := os.Getenv("SUBMISSION_DIR")
+	if format == "" {
+		return "Aufgabenblatt%d", nil
+	}
+	if strings.Count(format, "%d") != 1 || strings.Count(format, "%") != 1 {
+		return "", errors.New("SUBMISSION_DIR must contain %d exactly once, like Aufgabenblatt%d")
+	}
+	return format, nil
+}
+
+// Get the directory of the solution of an exercise relative to the repository
+func submissionDir(number int) string {
+	format, err := submissionDirFormat()
+	if err != nil {
+		format = "Aufgabenblatt%d"
+	}
+	return treePath(fmt.Sprintf(format, number))
+}
+
+// The state of the submission of an exercise at its latest deadline
+type submissionStatus struct {
+	Exercise int
+	Dir      string
+	Due      time.Time
+	// The last own commit in the directory before the deadline, nil if there is none
+	Last *cachedCommit
+	// The commits that changed the directory after Last (or after the deadline if there is no Last)
+	After []*cachedCommit
+	// If the user ever committed in the directory
+	Pushed bool
+}
+
+// Find for every exercise with a deadline the last own commit in its directory before its latest deadline.
+// The bot only sees what was pushed, so a commit that shows up here was pushed.
+func submissionStatuses() ([]submissionStatus, error) {
+	head, err := getCurrentCommit()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := reachableCommits(plumbing.NewHash(head))
+	if err != nil {
+		return nil, err
+	}
+	sortCommits(commits, orderTopological)
+
+	// The deadlines are sorted, so the last one of an exercise wins
+	latest := make(map[int]time.Time)
+	order := make([]int, 0)
+	for _, d := range getDeadlines() {
+		if _, ok := latest[d.Exercise]; !ok {
+			order = append(order, d.Exercise)
+		}
+		latest[d.Exercise] = d.Due
+	}
+
+	statuses := make([]submissionStatus, 0, len(order))
+	for _, number := range order {
+		status := submissionStatus{Exercise: number, Dir: submissionDir(number), Due: latest[number]}
+		for _, c := range commits {
+			if !c.touches(status.Dir) {
+				continue
+			}
+			if c.isOwn() {
+				status.Pushed = true
+				if !c.CommitterWhen.After(status.Due) {
+					status.Last = c
+					status.After = nil
+					continue
+				}
+			}
+			if status.Last != nil || c.CommitterWhen.After(status.Due) {
+				status.After = append(status.After, c)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Format the submission status of an exercise for a message
+func formatSubmissionStatus(status submissionStatus) (string, error) {
+	message := fmt.Sprintf("*Exercise %d* (deadline %s", status.Exercise, formatDue(status.Due))
+	if status.Due.After(time.Now()) {
+		message += fmt.Sprintf(", in %s", formatRemaining(time.Until(status.Due)))
+	}
+	message += ")\n"
+
+	if !status.Pushed {
+		return message + fmt.Sprintf("⚠️ Nothing was pushed in `%s` yet", status.Dir), nil
+	}
+
+	if status.Last == nil {
+		message += "⚠️ Nothing was pushed before the deadline"
+	} else {
+		commits, err := loadCommits([]*cachedCommit{status.Last})
+		if err != nil {
+			return "", err
+		}
+		title := strings.SplitN(strings.TrimSpace(commits[0].Message), "\n", 2)[0]
+		message += fmt.Sprintf("✅ Last commit before the deadline: `%s` %s (%s)", status.Last.Hash[:7], title,
+			status.Last.CommitterWhen.Local().Format("02.01.2006 15:04"))
+	}
+
+	if len(status.After) > 0 {
+		since := "it"
+		if status.Last == nil {
+			since = "the deadline"
+		}
+		commits := fmt.Sprintf("%d commits", len(status.After))
+		if len(status.After) == 1 {
+			commits = "1 commit"
+		}
+		last := status.After[len(status.After)-1]
+		message += fmt.Sprintf("\n⚠️ %s changed `%s` after %s, the last one on %s", commits, status.Dir, since,
+			last.CommitterWhen.Local().Format("02.01.2006 15:04"))
+	}
+	return message, nil
+}
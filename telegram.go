@@ -1,23 +1,29 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
 	gitobject "github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/jasonlvhit/gocron"
-	"log"
+	"github.com/sirupsen/logrus"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var buildDate = "<__unknown__>"
 
 func handleMessage(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
-	log.Printf("[%s] %s", update.Message.From.UserName, update.Message.Text)
+	startRequest(update, update.Message.Command()).Debug("Received message")
+	defer finishRequest(update, update.Message.Command())
 
 	// Call the right function to handle the command
 	switch update.Message.Command() {
@@ -39,6 +45,22 @@ func handleMessage(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 		downloadCmd(bot, update)
 	case "history":
 		historyCmd(bot, update)
+	case "log":
+		logCmd(bot, update)
+	case "blame":
+		blameCmd(bot, update)
+	case "grep":
+		grepCmd(bot, update)
+	case "search_log", "searchlog":
+		searchLogCmd(bot, update)
+	case "deadline", "deadlines":
+		deadlineCmd(bot, update)
+	case "calendar":
+		calendarCmd(bot, update)
+	case "grades":
+		gradesCmd(bot, update)
+	case "status":
+		statusCmd(bot, update)
 	case "statistic":
 		statisticCmd(bot, update)
 	case "start":
@@ -52,25 +74,36 @@ func handleMessage(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 	default:
 		// Ignore non-command messages in group chats
 		if update.Message.Chat.Type != "private" && !update.Message.IsCommand() {
+			setRequestResult(update, "ignored")
 			return
 		}
 
 		// Send a message to show that the bot is confused
+		setRequestResult(update, "unknown")
 		sendMessage(bot, update, "Sorry, I don't know that command.\nType /help to see what I know.")
 	}
 }
 
 func handleCallBackQuery(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
-	log.Printf("[%s] %s", update.CallbackQuery.From.UserName, update.CallbackQuery.Data)
-
 	data := strings.SplitN(update.CallbackQuery.Data, " ", 2)
+	startRequest(update, "callback:"+data[0]).WithField("data", update.CallbackQuery.Data).Debug("Received callback")
+	defer finishRequest(update, "callback:"+data[0])
+
+	if handleBrowseCallback(bot, update.CallbackQuery, data) {
+		return
+	}
+	if handlePageCallback(bot, update.CallbackQuery, data) {
+		return
+	}
+
 	switch data[0] {
 	case "download":
 		// Check if the file exists
 		file := data[1]
 		err := checkPath(file)
 		if err != nil {
-			log.Printf("Unable to find file: %s", file)
+			setRequestResult(update, "error")
+			requestLog(update).WithError(err).WithField("file", file).Warn("Unable to find file")
 			return
 		}
 
@@ -80,7 +113,32 @@ func handleCallBackQuery(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 		// Upload a file
 		msg := tgbotapi.NewDocumentUpload(update.CallbackQuery.Message.Chat.ID, path.Join(getGitDir(), file))
 		msg.Caption = hideSecrets(file)
-		_, _ = bot.Send(msg)
+		_, _ = send(bot, msg)
+	case "exercise":
+		number, _ := strconv.Atoi(data[len(data)-1])
+		sendExercise(bot, update.CallbackQuery.Message.Chat.ID, number)
+		_, _ = bot.AnswerCallbackQuery(tgbotapi.NewCallback(update.CallbackQuery.ID, ""))
+	}
+}
+
+// Long poll Telegram for updates and send them into the channel, like tgbotapi.GetUpdatesChan does. This also
+// records every successful poll, so the health check can tell if polling got stuck.
+func pollUpdates(bot *tgbotapi.BotAPI, config tgbotapi.UpdateConfig, updates chan<- tgbotapi.Update) {
+	for {
+		received, err := bot.GetUpdates(config)
+		if err != nil {
+			logger.WithError(err).Warn("Unable to get the updates, retrying in 3 seconds")
+			time.Sleep(3 * time.Second)
+			continue
+		}
+		recordPoll()
+
+		for _, update := range received {
+			if update.UpdateID >= config.Offset {
+				config.Offset = update.UpdateID + 1
+				updates <- update
+			}
+		}
 	}
 }
 
@@ -93,66 +151,101 @@ func startBackgroundManager(bot *tgbotapi.BotAPI) {
 	// Setup the automatic call of backgroundJob
 	go func() {
 		gocron.Every(30).Minutes().Do(backgroundJob, bot)
+		gocron.Every(1).Minute().Do(reminderJob, bot)
 		<-gocron.Start()
 	}()
 }
 
 func backgroundJob(bot *tgbotapi.BotAPI) {
+	log := jobLog("pull")
+	start := time.Now()
+
 	// Get the current Hash
 	oldHash, err := getCurrentCommit()
 	if err != nil {
-		log.Println("An error occourced with the background task:", err.Error())
+		log.WithError(err).Error("Unable to get the current commit")
 		return
 	}
-	fmt.Println("Old Hash", oldHash)
 
 	// Pull the repo
-	err = pull()
+	changes, err := pull(log)
 	if err != nil {
-		log.Println("An error occourced with the background task, while pulling the repo:", err.Error())
+		log.WithError(err).Error("Unable to pull the repository")
+		recordPullFailure(bot, err)
 		return
 	}
+	recordPullSuccess(bot)
+	// Check the own submissions once the users got the updates
+	defer runValidation(bot)
 	cur, _ := getCurrentCommit()
-	fmt.Println("New Hash", cur)
+	log = log.WithFields(logrus.Fields{"old_hash": oldHash, "new_hash": cur})
+
+	// Tell the admin if the history was rewritten, the local clone was already reset to the new history
+	for _, message := range formatRewrites(changes) {
+		sendMessageTo(bot, int64(getAdmin()), message)
+	}
 
 	// Get all the new commits
 	newCommits, err := historySince(oldHash)
 	if err != nil {
-		log.Println("An error occourced with the background task, pulling worked fine but now I can't get the commits.", err.Error())
+		log.WithError(err).Error("Pulling worked fine, but now I can't get the new commits")
 		return
 	}
 
+	commitsDetected.Add(float64(len(newCommits)))
+
 	// Filter commits from the users. The users committed them so why would they want to see them ?
-	newFilteredCommits := make([]gitobject.Commit, 0, 0)
-	for _, c := range newCommits {
-		if strings.Contains(c.Author.Email, getGitUser()) && getGitUser() != "" {
-			continue
+	// Commits with private files like the feedback of the tutors are only for the admin.
+	newFilteredCommits, privateCommits := splitPrivateCommits(filterOwnCommits(newCommits))
+
+	// Create the messages for the new commits and the changed branches and tags
+	messages := make([]string, 0)
+	if len(newFilteredCommits) > 0 {
+		message := "*New commits:*🎉🎊\n"
+		for _, commit := range newFilteredCommits {
+			message += formatCommit(commit)
 		}
+		messages = append(messages, message)
+	}
+	messages = append(messages, formatRefChanges(changes, false)...)
 
-		newFilteredCommits = append(newFilteredCommits, c)
+	// New and corrected exercise sheets get their own announcement, so they don't get lost between the commits
+	announcements, err := exerciseAnnouncements(oldHash, cur)
+	if err != nil {
+		log.WithError(err).Error("Unable to find the changed exercises")
 	}
+	announceDeadlines(announcements)
+	diffSheets(announcements)
 
-	// Check if there are new commits to notify
-	if len(newFilteredCommits) == 0 {
-		log.Println("Backgroundjob ran, no new commits to send the users.")
+	// Check if there is something to notify
+	if len(messages) == 0 && len(announcements) == 0 && len(privateCommits) == 0 {
+		log.WithField("duration_ms", time.Since(start).Milliseconds()).Info("Background job ran, nothing to send the users")
 		return
 	}
 
-	// Create a message and send it the user
-	message := "*New commits:*🎉🎊\n"
-	for _, commit := range newFilteredCommits {
-		message += formatCommit(commit)
-	}
+	sendPrivateCommits(bot, int64(getAdmin()), privateCommits, cur)
 
 	// Send the messages to the subscribed users
 	subscribed := getUsers()
 	for _, subscription := range subscribed {
-		msg := tgbotapi.NewMessage(subscription, hideSecrets(message))
-		msg.ParseMode = "Markdown"
-		_, _ = bot.Send(msg)
+		for _, message := range messages {
+			msg := tgbotapi.NewMessage(subscription, hideSecrets(message))
+			msg.ParseMode = "Markdown"
+			_, _ = send(bot, msg)
+		}
+		for _, announcement := range announcements {
+			sendExerciseAnnouncement(bot, subscription, announcement)
+		}
 	}
 
-	log.Println("Backgroundjob ran, sent the users the updates.")
+	log.WithFields(logrus.Fields{
+		"duration_ms":   time.Since(start).Milliseconds(),
+		"commits":       len(newFilteredCommits),
+		"private":       len(privateCommits),
+		"messages":      len(messages),
+		"announcements": len(announcements),
+		"subscribers":   len(subscribed),
+	}).Info("Background job ran, sent the users the updates")
 }
 
 func lsCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
@@ -162,17 +255,18 @@ func lsCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 		return
 	}
 
-	files, err := listFiles(update.Message.CommandArguments())
+	dir, rev := splitRevision(update.Message.CommandArguments())
+	text, keyboard, err := renderDirectory(browseLocation{Path: cleanBrowsePath(dir), Rev: rev}, 0)
 	if err != nil {
-		sendMessage(bot, update, fmt.Sprintf("An error occoured while listing the files.\n`Error: %s`", err.Error()))
+		sendError(bot, update, "An error occoured while listing the files.", err)
 		return
 	}
 
-	message := ""
-	for _, file := range files {
-		message += file + "\n"
-	}
-	sendMessage(bot, update, message)
+	// Send the browser, which will be edited when the user presses the buttons
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, hideSecrets(text))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	_, _ = send(bot, msg)
 }
 
 func catCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
@@ -182,15 +276,8 @@ func catCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 		return
 	}
 
-	content, err := readFile(update.Message.CommandArguments())
-	if err != nil {
-		sendMessage(bot, update, fmt.Sprintf("An error occoured while reading a file.\n`Error: %s`", err.Error()))
-		return
-	}
-
-	_, filename := filepath.Split(update.Message.CommandArguments())
-	message := fmt.Sprintf("*%s*\n```%s```", filename, string(content))
-	sendMessage(bot, update, message)
+	file, rev := splitRevision(update.Message.CommandArguments())
+	sendFileContent(bot, update.Message.Chat.ID, file, rev)
 }
 
 func downloadCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
@@ -200,15 +287,42 @@ func downloadCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 		return
 	}
 
-	path := filepath.Join(getGitDir(), update.Message.CommandArguments())
+	// Directories get zipped, files from the working tree are sent directly and older versions are read from git
+	target, rev := splitRevision(update.Message.CommandArguments())
+	isDir, err := isDirectory(target, rev)
+	if err != nil {
+		sendMessage(bot, update, fmt.Sprintf("Unable to send you the file\n`Error: %s`", err.Error()))
+		return
+	}
+	if !isDir && rev == "" {
+		sendFile(bot, update, filepath.Join(getGitDir(), target))
+		return
+	}
+	if !isDir {
+		content, err := readFile(target, rev)
+		if err != nil {
+			sendMessage(bot, update, fmt.Sprintf("Unable to send you the file\n`Error: %s`", err.Error()))
+			return
+		}
+		sendBytesTo(bot, update.Message.Chat.ID, filepath.Base(target), content)
+		return
+	}
 
-	sendFile(bot, update, path)
+	sendAction(bot, update, tgbotapi.ChatUploadDocument)
+	name, file, size, err := zipDirectoryToFile(target, rev)
+	if err != nil {
+		sendMessage(bot, update, fmt.Sprintf("Unable to create the archive\n`Error: %s`", err.Error()))
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	sendReaderTo(bot, update.Message.Chat.ID, name, file, size)
 }
 
 func readmeCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
-	content, err := readFile("README.md")
+	content, err := readFile("README.md", "")
 	if err != nil {
-		sendMessage(bot, update, fmt.Sprintf("An error occoured while reading a file.\n`Error: %s`", err.Error()))
+		sendError(bot, update, "An error occoured while reading a file.", err)
 		return
 	}
 
@@ -226,52 +340,65 @@ func exerciseCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 			return
 		}
 
-		file := fmt.Sprintf("angabe/Aufgabenblatt%d.pdf", number)
-		_, err = readFile(file)
-		if err != nil {
-			sendMessage(bot, update, fmt.Sprintf("There is no exercise %d", number))
-			return
-		}
-
-		sendFile(bot, update, path.Join(getGitDir(), file))
+		sendExercise(bot, update.Message.Chat.ID, number)
 		return
 	}
 
-	// Get all files of angabe
-	allFiles, err := listFilesRaw("angabe")
+	// Get all exercises
+	exercises, err := exerciseCatalog()
 	if err != nil {
-		sendMessage(bot, update, fmt.Sprintf("An error occoured while reading the exercise directory.\n`Error: %s`", err.Error()))
+		sendError(bot, update, "An error occoured while reading the exercise directory.", err)
 		return
 	}
 
-	// Save the PDFs to a new list
-	files := make([]string, 0, len(allFiles)/2)
-	for _, file := range allFiles {
-		if strings.HasSuffix(file, ".pdf") {
-			files = append(files, file)
-		}
-	}
-
 	// Build the inline keyboard
 	rows := make([][]tgbotapi.InlineKeyboardButton, 0)
-	for _, file := range files {
-		callback := fmt.Sprintf("download angabe/%s", file)
-		row := []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(file, callback)}
+	for _, e := range exercises {
+		text := fmt.Sprintf("Exercise %d", e.Number)
+		if len(e.Files) == 1 {
+			text = path.Base(e.Files[0])
+		} else {
+			text += fmt.Sprintf(" (%d files)", len(e.Files))
+		}
+		row := []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(text, fmt.Sprintf("exercise %d", e.Number))}
 		rows = append(rows, row)
 	}
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
 
-	// Show the user all possible exercises
-	message := fmt.Sprintf("There are %d exercises:", len(files))
+	// Show the user all possible exercises and when they are due
+	message := fmt.Sprintf("There are %d exercises:", len(exercises))
+	for _, e := range exercises {
+		for _, d := range exerciseDeadlines(e.Number) {
+			message += fmt.Sprintf("\n⏰ %s: %s", d.Title, formatDue(d.Due))
+		}
+	}
 	message = hideSecrets(message)
 	msg := tgbotapi.NewMessage(update.Message.Chat.ID, message)
 	msg.ParseMode = "Markdown"
 	msg.DisableWebPagePreview = true
-	msg.ReplyMarkup = keyboard
-	_, _ = bot.Send(msg)
+	if len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	_, _ = send(bot, msg)
 
 }
 
+// Send all files of the exercise
+func sendExercise(bot *tgbotapi.BotAPI, chatID int64, number int) {
+	e, ok, err := findExercise(number)
+	if err != nil {
+		sendMessageTo(bot, chatID, fmt.Sprintf("An error occoured while reading the exercise directory.\n`Error: %s`", err.Error()))
+		return
+	}
+	if !ok {
+		sendMessageTo(bot, chatID, fmt.Sprintf("There is no exercise %d", number))
+		return
+	}
+
+	for _, file := range e.Files {
+		sendFileTo(bot, chatID, path.Join(getGitDir(), file))
+	}
+}
+
 func subscribeCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 	if isUser(update.Message.Chat.ID) {
 		sendMessage(bot, update, "This channel is already subscribed")
@@ -280,7 +407,7 @@ func subscribeCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 
 	err := addUser(update.Message.Chat.ID)
 	if err != nil {
-		sendMessage(bot, update, fmt.Sprintf("An error occoured while reading adding the subscription.\n`Error: %s`", err.Error()))
+		sendError(bot, update, "An error occoured while reading adding the subscription.", err)
 		return
 	}
 	message := "This channel is now subscribed"
@@ -295,7 +422,7 @@ func unsubscribeCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 
 	err := removeUser(update.Message.Chat.ID)
 	if err != nil {
-		sendMessage(bot, update, fmt.Sprintf("An error occoured while reading deleting the subscription.\n`Error: %s`", err.Error()))
+		sendError(bot, update, "An error occoured while reading deleting the subscription.", err)
 		return
 	}
 	message := "This channel is no longer subscribed"
@@ -305,61 +432,90 @@ func unsubscribeCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 func pullCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 	oldHash, err := getCurrentCommit()
 	if err != nil {
-		sendMessage(bot, update, fmt.Sprintf("An error occoured while pulling the repository.\n`Error: %s`", err.Error()))
+		sendError(bot, update, "An error occoured while pulling the repository.", err)
 		return
 	}
 
-	err = pull()
+	changes, err := pull(requestLog(update))
 	if err != nil {
-		sendMessage(bot, update, fmt.Sprintf("An error occoured while pulling the repository.\n`Error: %s`", err.Error()))
+		sendError(bot, update, "An error occoured while pulling the repository.", err)
 		return
 	}
+	defer runValidation(bot)
 
 	newCommits, err := historySince(oldHash)
 	if err != nil {
-		sendMessage(bot, update, fmt.Sprintf("Pulling worked fine, however I cannot get the commits new with this pull.\n`Error: %s`", err.Error()))
+		sendError(bot, update, "Pulling worked fine, however I cannot get the commits new with this pull.", err)
 		return
 	}
 
-	if len(newCommits) == 0 {
+	commitsDetected.Add(float64(len(newCommits)))
+
+	if len(newCommits) == 0 && len(changes) == 0 {
 		sendMessage(bot, update, "Repository is already up to date.")
 		return
 	}
 
+	// Commits with private files like the feedback of the tutors are only for the admin, who gets them in their own
+	// message with the files attached.
+	publicCommits, privateCommits := splitPrivateCommits(newCommits)
+
 	// Filter commits from the users. The users committed them so why would they want to see them ?
-	newFilteredCommits := make([]gitobject.Commit, 0, 0)
-	for _, c := range newCommits {
-		if strings.Contains(c.Author.Email, getGitUser()) && getGitUser() != "" {
-			continue
-		}
+	newFilteredCommits := filterOwnCommits(publicCommits)
+	refMessages := formatRefChanges(changes, false)
 
-		newFilteredCommits = append(newFilteredCommits, c)
+	cur, _ := getCurrentCommit()
+	announcements, err := exerciseAnnouncements(oldHash, cur)
+	if err != nil {
+		requestLog(update).WithError(err).Error("Unable to find the changed exercises")
 	}
-
-	// Create a message for the admin
-	adminMessage := "*New commits:*🎉🎊\n"
-	for _, commit := range newCommits {
-		adminMessage += formatCommit(commit)
+	announceDeadlines(announcements)
+	diffSheets(announcements)
+
+	// Create the messages for the admin
+	adminMessages := make([]string, 0)
+	if len(publicCommits) > 0 {
+		adminMessage := "*New commits:*🎉🎊\n"
+		for _, commit := range publicCommits {
+			adminMessage += formatCommit(commit)
+		}
+		adminMessages = append(adminMessages, adminMessage)
 	}
-
-	// Send the admin the message
-	if len(newFilteredCommits) > 0 || isAdmin(update.Message.From.ID) {
-		msg := tgbotapi.NewMessage(int64(getAdmin()), hideSecrets(adminMessage))
-		msg.ParseMode = "Markdown"
-		_, _ = bot.Send(msg)
+	adminMessages = append(adminMessages, formatRefChanges(changes, true)...)
+	rewrites := formatRewrites(changes)
+	adminMessages = append(adminMessages, rewrites...)
+
+	// Send the admin the messages
+	if len(newFilteredCommits) > 0 || len(refMessages) > 0 || len(rewrites) > 0 || len(announcements) > 0 ||
+		len(privateCommits) > 0 || isAdmin(update.Message.From.ID) {
+		for _, adminMessage := range adminMessages {
+			msg := tgbotapi.NewMessage(int64(getAdmin()), hideSecrets(adminMessage))
+			msg.ParseMode = "Markdown"
+			_, _ = send(bot, msg)
+		}
+		sendPrivateCommits(bot, int64(getAdmin()), privateCommits, cur)
+		for _, announcement := range announcements {
+			sendExerciseAnnouncement(bot, int64(getAdmin()), announcement)
+		}
 	}
 
 	// Don't send the normal users private commits
-	if !isAdmin(update.Message.From.ID) && len(newFilteredCommits) == 0 {
+	if !isAdmin(update.Message.From.ID) && len(newFilteredCommits) == 0 && len(refMessages) == 0 &&
+		len(announcements) == 0 {
 		sendMessage(bot, update, "Repository is already up to date.")
 		return
 	}
 
-	// Create a message and send it the users
-	message := "*New commits:*🎉🎊\n"
-	for _, commit := range newFilteredCommits {
-		message += formatCommit(commit)
+	// Create the messages for the users
+	messages := make([]string, 0)
+	if len(newFilteredCommits) > 0 {
+		message := "*New commits:*🎉🎊\n"
+		for _, commit := range newFilteredCommits {
+			message += formatCommit(commit)
+		}
+		messages = append(messages, message)
 	}
+	messages = append(messages, refMessages...)
 
 	// Send the messages to the subscribed users (except the admin, cause he already got a message)
 	subscribed := getUsers()
@@ -367,52 +523,80 @@ func pullCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 		if int64(getAdmin()) == subscription {
 			continue
 		}
-		msg := tgbotapi.NewMessage(subscription, hideSecrets(message))
-		msg.ParseMode = "Markdown"
-		_, _ = bot.Send(msg)
+		for _, message := range messages {
+			msg := tgbotapi.NewMessage(subscription, hideSecrets(message))
+			msg.ParseMode = "Markdown"
+			_, _ = send(bot, msg)
+		}
+		for _, announcement := range announcements {
+			sendExerciseAnnouncement(bot, subscription, announcement)
+		}
 	}
 }
 
 func historyCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
-	// Get all commits from the repository
-	commits, err := history()
+	// Get all commits from the repository, only the cached information is needed until we know which commits to show
+	current, err := getCurrentCommit()
+	if err != nil {
+		sendError(bot, update, "An error occoured while reading the repository.", err)
+		return
+	}
+	all, err := reachableCommits(plumbing.NewHash(current))
 	if err != nil {
-		sendMessage(bot, update, fmt.Sprintf("An error occoured while reading the repository.\n`Error: %s`", err.Error()))
+		sendError(bot, update, "An error occoured while reading the repository.", err)
 		return
 	}
 
-	// For non-admin users we filter the commits so that they can only see the ones by faculty members
-	if !isAdmin(update.Message.From.ID) {
-		filtered := make([]gitobject.Commit, 0, len(commits))
-		for _, c := range commits {
-			// Don't append the commits where the matriculation number appears in
-			if strings.Contains(c.Author.Email, getGitUser()) && getGitUser() != "" {
-				continue
+	// For non-admin users we filter the commits so that they can only see the ones by faculty members, private
+	// commits are only shown to the admin
+	cached := all
+	admin := isAdmin(update.Message.From.ID)
+	private := showsPrivate(update.Message.From.ID, update.Message.Chat.ID)
+	if !admin || !private {
+		cached = make([]*cachedCommit, 0, len(all))
+		for _, c := range all {
+			if (admin || !c.isOwn()) && (private || !c.isPrivate()) {
+				cached = append(cached, c)
 			}
-
-			filtered = append(filtered, c)
 		}
-		commits = filtered
 	}
 
-	// Split the arguments
-	rawarg := strings.TrimSpace(update.Message.CommandArguments())
-	args := strings.SplitN(rawarg, " ", 2)
-
-	// By default we return 5 commits, unless the user specifies otherwise
+	// By default we return the tail of the 5 newest commits sorted by the graph, unless the user specifies otherwise
+	head := false
 	number := 5
-	if n, err := strconv.ParseInt(args[len(args)-1], 10, 0); err == nil {
-		number = int(n)
+	order := orderTopological
+	for _, arg := range strings.Fields(update.Message.CommandArguments()) {
+		if n, err := strconv.ParseInt(arg, 10, 0); err == nil {
+			number = int(n)
+		} else if o, ok := parseCommitOrder(arg); ok {
+			order = o
+		} else if arg == "head" {
+			head = true
+		}
+	}
+	// A negative number would cut the commits past their end and with zero the message would be empty
+	if number < 1 {
+		number = 1
 	}
+	if len(cached) == 0 {
+		sendMessage(bot, update, "There are no commits.")
+		return
+	}
+	sortCommits(cached, order)
 
 	// By default we only give the user the tail of the commits, unless he specifies the head
-	if (args[0] == "head") && len(commits) > number {
-		commits = commits[:number]
-	} else if len(commits) > number {
-		commits = commits[len(commits)-number:]
+	if head && len(cached) > number {
+		cached = cached[:number]
+	} else if len(cached) > number {
+		cached = cached[len(cached)-number:]
 	}
 
 	// Create the message from the selected commits
+	commits, err := loadCommits(cached)
+	if err != nil {
+		sendError(bot, update, "An error occoured while reading the repository.", err)
+		return
+	}
 	message := ""
 	for _, commit := range commits {
 		message += formatCommit(commit)
@@ -420,6 +604,358 @@ func historyCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 	sendMessage(bot, update, message)
 }
 
+func logCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
+	// The arguments are the path and optionally the number of commits
+	file := ""
+	number := 5
+	for _, arg := range strings.Fields(update.Message.CommandArguments()) {
+		if n, err := strconv.ParseInt(arg, 10, 0); err == nil {
+			number = int(n)
+		} else {
+			file = arg
+		}
+	}
+	// A negative number would cut the commits past their end
+	if number < 1 {
+		number = 1
+	}
+	if file == "" {
+		sendMessage(bot, update, "You need to tell me the path, for example: /log angabe/Aufgabenblatt1.pdf")
+		return
+	}
+
+	commits, err := fileHistory(file)
+	if err != nil {
+		sendError(bot, update, "An error occoured while reading the history.", err)
+		return
+	}
+
+	// For non-admin users we filter the commits so that they can only see the ones by faculty members
+	if !isAdmin(update.Message.From.ID) {
+		commits = filterOwnCommits(commits)
+	}
+	if !showsPrivate(update.Message.From.ID, update.Message.Chat.ID) {
+		commits = filterPrivateCommits(commits)
+	}
+	if len(commits) == 0 {
+		sendMessage(bot, update, fmt.Sprintf("There are no commits for `%s`", file))
+		return
+	}
+
+	if len(commits) > number {
+		commits = commits[len(commits)-number:]
+	}
+	message := fmt.Sprintf("*History of* `%s`\n\n", file)
+	for _, commit := range commits {
+		message += formatCommit(commit)
+	}
+	sendMessage(bot, update, message)
+}
+
+func blameCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
+	// Only admin is allowed to see who wrote the files
+	if !isAdmin(update.Message.From.ID) {
+		sendMessageAdminNeeded(bot, update)
+		return
+	}
+
+	file := strings.TrimSpace(update.Message.CommandArguments())
+	if file == "" {
+		sendMessage(bot, update, "You need to tell me the path, for example: /blame README.md")
+		return
+	}
+
+	sendAction(bot, update, tgbotapi.ChatTyping)
+	authors, lines, err := blameFile(file)
+	if err != nil {
+		sendError(bot, update, "An error occoured while reading the authors.", err)
+		return
+	}
+
+	message := fmt.Sprintf("*Blame of* `%s`\n%d lines by %d authors\n\n", file, lines, len(authors))
+	for _, author := range authors {
+		message += fmt.Sprintf("`%s` %d lines (%d%%), last change %s\n",
+			author.Author,
+			author.Lines,
+			author.Lines*100/lines,
+			author.Last.Local().Format("02.01.2006 15:04"),
+		)
+	}
+
+	// Show the last commit that changed the file
+	commits, err := fileHistory(file)
+	if err == nil && len(commits) > 0 {
+		message += "\n*Last change:*\n" + formatCommit(commits[len(commits)-1])
+	}
+	sendMessage(bot, update, message)
+}
+
+func grepCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
+	// Only admin is allowed to read files
+	if !isAdmin(update.Message.From.ID) {
+		sendMessageAdminNeeded(bot, update)
+		return
+	}
+
+	// The arguments are the flags, the pattern and optionally the directory to search in
+	ignoreCase := false
+	limit := 100
+	pattern, dir := "", ""
+	args := strings.Fields(update.Message.CommandArguments())
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-i":
+			ignoreCase = true
+		case args[i] == "-m" && i+1 < len(args):
+			if n, err := strconv.Atoi(args[i+1]); err == nil && n > 0 {
+				limit = n
+			}
+			i++
+		case pattern == "":
+			pattern = args[i]
+		default:
+			dir = args[i]
+		}
+	}
+	if pattern == "" {
+		sendMessage(bot, update, "You need to tell me what to search, for example: /grep -i \"class Main\" src\n"+
+			"Use -i to ignore the case and -m to set the maximum number of results.")
+		return
+	}
+
+	// Quotes let the user search for patterns with spaces, which strings.Fields split apart
+	if strings.HasPrefix(pattern, "\"") {
+		quoted := regexp.MustCompile(`"([^"]*)"\s*(\S*)\s*$`).FindStringSubmatch(update.Message.CommandArguments())
+		if quoted != nil {
+			pattern, dir = quoted[1], quoted[2]
+		}
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		sendMessage(bot, update, fmt.Sprintf("That is not a valid pattern.\n`Error: %s`", err.Error()))
+		return
+	}
+
+	sendAction(bot, update, tgbotapi.ChatTyping)
+	matches, truncated, err := grepFiles(re, dir, limit)
+	if err != nil {
+		sendError(bot, update, "An error occoured while searching the files.", err)
+		return
+	}
+	if len(matches) == 0 {
+		sendMessage(bot, update, "Nothing found.")
+		return
+	}
+
+	items := make([]string, 0, len(matches))
+	for _, match := range matches {
+		// Backticks would end the code block
+		text := truncateText(strings.ReplaceAll(strings.TrimSpace(match.Text), "`", "'"), 200)
+		items = append(items, fmt.Sprintf("`%s:%d:` `%s`", match.Path, match.Line, text))
+	}
+	header := fmt.Sprintf("*Found %d matches:*", len(matches))
+	if truncated {
+		header = fmt.Sprintf("*Found more than %d matches, these are the first:*", limit)
+	}
+	sendPagedTo(bot, update.Message.Chat.ID, header, items, 15)
+}
+
+func searchLogCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
+	text := strings.TrimSpace(update.Message.CommandArguments())
+	if text == "" {
+		sendMessage(bot, update, "You need to tell me what to search, for example: /search_log Aufgabenblatt")
+		return
+	}
+
+	commits, err := searchLog(text)
+	if err != nil {
+		sendError(bot, update, "An error occoured while reading the repository.", err)
+		return
+	}
+
+	// For non-admin users we filter the commits so that they can only see the ones by faculty members
+	if !isAdmin(update.Message.From.ID) {
+		commits = filterOwnCommits(commits)
+	}
+	if !showsPrivate(update.Message.From.ID, update.Message.Chat.ID) {
+		commits = filterPrivateCommits(commits)
+	}
+	if len(commits) == 0 {
+		sendMessage(bot, update, "Nothing found.")
+		return
+	}
+
+	// Show the newest commits first, since they are most likely the ones searched for
+	items := make([]string, 0, len(commits))
+	for i := len(commits) - 1; i >= 0; i-- {
+		items = append(items, formatCommit(commits[i]))
+	}
+	sendPagedTo(bot, update.Message.Chat.ID, fmt.Sprintf("*Found %d commits:*\n", len(commits)), items, 5)
+}
+
+func deadlineCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
+	fields := strings.Fields(update.Message.CommandArguments())
+	if len(fields) == 0 || fields[0] == "list" {
+		deadlineListCmd(bot, update)
+		return
+	}
+
+	// Only the admin is allowed to change the deadlines
+	if !isAdmin(update.Message.From.ID) {
+		sendMessageAdminNeeded(bot, update)
+		return
+	}
+
+	switch fields[0] {
+	case "add":
+		// The arguments are the exercise, the date with an optional time and an optional title
+		usage := "Usage: /deadline add <exercise> <dd.mm.yyyy> [hh:mm] [title]\nFor example: /deadline add 3 12.05.2020 23:55"
+		if len(fields) < 3 {
+			sendMessage(bot, update, usage)
+			return
+		}
+		number, err := strconv.Atoi(fields[1])
+		if err != nil {
+			sendMessage(bot, update, "The exercise musst be a number but was: "+fields[1]+"\n"+usage)
+			return
+		}
+		rest := strings.TrimSpace(strings.SplitN(update.Message.CommandArguments(), fields[1], 2)[1])
+		match := germanDateRegex.FindStringSubmatch(rest)
+		if match == nil || !strings.HasPrefix(rest, match[0]) {
+			sendMessage(bot, update, "I don't understand the date.\n"+usage)
+			return
+		}
+		due, ok := parseGermanDate(match)
+		if !ok {
+			sendMessage(bot, update, "That date does not exist.\n"+usage)
+			return
+		}
+		title := strings.TrimSpace(strings.TrimPrefix(rest, match[0]))
+		if title == "" {
+			title = fmt.Sprintf("Exercise %d", number)
+		}
+
+		d, err := addDeadline(number, title, due)
+		if err != nil {
+			sendError(bot, update, "An error occoured while saving the deadline.", err)
+			return
+		}
+		sendMessage(bot, update, fmt.Sprintf("Added deadline %d: %s on %s", d.ID, d.Title, formatDue(d.Due)))
+
+	case "remove":
+		if len(fields) < 2 {
+			sendMessage(bot, update, "Usage: /deadline remove <id>\nYou can see the ids with /deadline list")
+			return
+		}
+		id, err := strconv.Atoi(fields[1])
+		if err != nil {
+			sendMessage(bot, update, "The id musst be a number but was: "+fields[1])
+			return
+		}
+		ok, err := removeDeadline(id)
+		if err != nil {
+			sendError(bot, update, "An error occoured while removing the deadline.", err)
+			return
+		}
+		if !ok {
+			sendMessage(bot, update, fmt.Sprintf("There is no deadline %d", id))
+			return
+		}
+		sendMessage(bot, update, fmt.Sprintf("Removed deadline %d", id))
+
+	default:
+		sendMessage(bot, update, "I only know /deadline list, /deadline add and /deadline remove.")
+	}
+}
+
+func calendarCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
+	sendAction(bot, update, tgbotapi.ChatUploadDocument)
+	content, err := createCalendar()
+	if err != nil {
+		sendError(bot, update, "An error occoured while creating the calendar.", err)
+		return
+	}
+	sendBytesTo(bot, update.Message.Chat.ID, "ep2.ics", content)
+}
+
+func gradesCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
+	// The grades are private, so only the admin can see them and not in a group chat
+	if !isAdmin(update.Message.From.ID) {
+		sendMessageAdminNeeded(bot, update)
+		return
+	}
+	if !showsPrivate(update.Message.From.ID, update.Message.Chat.ID) {
+		sendMessagePrivateChatNeeded(bot, update)
+		return
+	}
+
+	grades, err := collectGrades()
+	if err != nil {
+		sendError(bot, update, "An error occoured while collecting the grades.", err)
+		return
+	}
+	if len(grades) == 0 {
+		sendMessage(bot, update, "There are no points in the feedback files yet.")
+		return
+	}
+
+	sendMessage(bot, update, fmt.Sprintf("*Grades:*\n```\n%s\n```", formatGrades(grades)))
+}
+
+func statusCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
+	// Only the admin is allowed to see the own commits and deadlines, and not in a group chat
+	if !isAdmin(update.Message.From.ID) {
+		sendMessageAdminNeeded(bot, update)
+		return
+	}
+	if !showsPrivate(update.Message.From.ID, update.Message.Chat.ID) {
+		sendMessagePrivateChatNeeded(bot, update)
+		return
+	}
+
+	statuses, err := submissionStatuses()
+	if err != nil {
+		sendError(bot, update, "An error occoured while checking the submissions.", err)
+		return
+	}
+	if len(statuses) == 0 {
+		sendMessage(bot, update, "There are no exercises with a deadline.")
+		return
+	}
+
+	messages := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		message, err := formatSubmissionStatus(status)
+		if err != nil {
+			sendError(bot, update, "An error occoured while checking the submissions.", err)
+			return
+		}
+		messages = append(messages, message)
+	}
+	sendMessage(bot, update, "*Submission status:*\n\n"+strings.Join(messages, "\n\n"))
+}
+
+func deadlineListCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
+	upcoming := upcomingDeadlines()
+	if len(upcoming) == 0 {
+		sendMessage(bot, update, "There are no upcoming deadlines.")
+		return
+	}
+
+	message := "*Upcoming deadlines:*"
+	for _, d := range upcoming {
+		message += fmt.Sprintf("\n⏰ %s: %s (in %s)", d.Title, formatDue(d.Due), formatRemaining(time.Until(d.Due)))
+		// The admin needs the ids to remove deadlines
+		if isAdmin(update.Message.From.ID) {
+			message += fmt.Sprintf(" `[%d, %s]`", d.ID, d.Source)
+		}
+	}
+	sendMessage(bot, update, message)
+}
+
 func broadcastCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 	if !isAdmin(update.Message.From.ID) {
 		sendMessage(bot, update, "Hey! Only the admin is allowed to perform this action. You shouldn't even know it exists 🤬!")
@@ -448,7 +984,7 @@ func broadcastCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 	for _, subscription := range subscribed {
 		msg := tgbotapi.NewMessage(subscription, hideSecrets(message))
 		msg.ParseMode = "Markdown"
-		_, _ = bot.Send(msg)
+		_, _ = send(bot, msg)
 	}
 
 }
@@ -468,14 +1004,23 @@ func nerdinfoCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 
 func helpCmd(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 	commands := `
-/ls - List all files in a directory
+/ls - Browse the files of a directory
 /cat - Print a file context in a chat message
-/download - Send a file
+/download - Send a file, directories are sent as zip
+(Append @commit, @branch, @tag or @HEAD~3 to the path to get an older version)
 /readme - Similar to /cat README.md
-/exercise - Display the exercise PDFs
+/exercise - Display the exercise sheets
 /subscribe - Send updates when new exercises get added
+/deadline - Show the upcoming deadlines
+/calendar - Get the releases and deadlines for your calendar
+/grades - Show the points of the exercises
+/status - Check if the solutions were pushed before the deadlines
 /unsubscribe - Unsubscribe from the updates
-/history - Send the git history
+/history - Send the git history (head, number and order: topo, date or author)
+/log - Send the history of a file or directory
+/blame - Show who wrote the lines of a file
+/grep - Search the files with a regex (-i to ignore the case)
+/search_log - Search the commit messages
 /pull - Pull the newest git changes
 /statistic - Send some information about the bot
 /nerdinfo - Information for nerds
@@ -496,37 +1041,156 @@ I was developed by my creator [flofriday](https://github.com/flofriday), and my
 }
 
 func sendMessageAdminNeeded(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
+	setRequestResult(update, "forbidden")
 	message := "Sorry, but for security reasons, only the admin is allowed to perform this action.\n\n" +
 		"However, there are good news 😄, you can download my code and deploy me on your own server, " +
 		"so that you can be the admin:\nhttps://github.com/flofriday/EP2-Bot"
 	sendMessage(bot, update, message)
 }
 
+func sendMessagePrivateChatNeeded(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
+	setRequestResult(update, "forbidden")
+	sendMessage(bot, update, "This is private, so I only show it to you in our private chat.")
+}
+
+// Tell the user that something went wrong and log the error with the update
+func sendError(bot *tgbotapi.BotAPI, update *tgbotapi.Update, text string, err error) {
+	setRequestResult(update, "error")
+	requestLog(update).WithError(err).Error(text)
+	sendMessage(bot, update, fmt.Sprintf("%s\n`Error: %s`", text, err.Error()))
+}
+
 func sendMessage(bot *tgbotapi.BotAPI, update *tgbotapi.Update, text string) {
+	sendMessageTo(bot, update.Message.Chat.ID, text)
+}
+
+func sendMessageTo(bot *tgbotapi.BotAPI, chatID int64, text string) {
 	text = hideSecrets(text)
-	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = "Markdown"
 	msg.DisableWebPagePreview = true
-	_, _ = bot.Send(msg)
+	_, _ = send(bot, msg)
+}
+
+// Telegram messages can't be longer than this many characters
+const maxMessageLength = 4096
+
+// Shorten the text to at most max characters, without cutting a character in half
+func truncateText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max]) + "..."
 }
 
 func sendFile(bot *tgbotapi.BotAPI, update *tgbotapi.Update, path string) {
+	sendFileTo(bot, update.Message.Chat.ID, path)
+}
+
+func sendFileTo(bot *tgbotapi.BotAPI, chatID int64, path string) {
 	err := checkPath(path)
 	if err != nil {
-		sendMessage(bot, update, fmt.Sprintf("Unable to send you the file\n`Error: %s`", err.Error()))
+		sendMessageTo(bot, chatID, fmt.Sprintf("Unable to send you the file\n`Error: %s`", err.Error()))
+		return
+	}
+
+	// Telegram would reject files that are too large anyway, but this way the user gets a clear error
+	if info, err := os.Stat(path); err == nil && info.Size() > maxUploadSize {
+		sendMessageTo(bot, chatID, fmt.Sprintf("Unable to send you the file\n`Error: the file is larger than the %d MB "+
+			"Telegram allows bots to upload`", maxUploadSize/1024/1024))
 		return
 	}
 
 	// Tell the client that we are uploading a file
-	sendAction(bot, update, tgbotapi.ChatUploadDocument)
+	_, _ = bot.Send(tgbotapi.NewChatAction(chatID, tgbotapi.ChatUploadDocument))
 
 	// Upload a file
-	msg := tgbotapi.NewDocumentUpload(update.Message.Chat.ID, path)
+	msg := tgbotapi.NewDocumentUpload(chatID, path)
 	msg.Caption = hideSecrets(msg.Caption)
-	_, err = bot.Send(msg)
+	_, err = send(bot, msg)
 	if err != nil {
-		log.Println("Error: ", err.Error())
-		sendMessage(bot, update, fmt.Sprintf("Unable to send you the file\n`Error: %s`", err.Error()))
+		logger.WithError(err).WithField("chat_id", chatID).Error("Unable to upload the file")
+		sendMessageTo(bot, chatID, fmt.Sprintf("Unable to send you the file\n`Error: %s`", err.Error()))
+	}
+}
+
+// Upload content that only exists in memory as a file
+func sendBytesTo(bot *tgbotapi.BotAPI, chatID int64, name string, content []byte) {
+	sendReaderTo(bot, chatID, name, bytes.NewReader(content), int64(len(content)))
+}
+
+// Upload the content of the reader as a file, the content is streamed to Telegram
+func sendReaderTo(bot *tgbotapi.BotAPI, chatID int64, name string, reader io.Reader, size int64) {
+	if size > maxUploadSize {
+		sendMessageTo(bot, chatID, fmt.Sprintf("Unable to send you the file\n`Error: the file is larger than the %d MB "+
+			"Telegram allows bots to upload`", maxUploadSize/1024/1024))
+		return
+	}
+
+	_, _ = bot.Send(tgbotapi.NewChatAction(chatID, tgbotapi.ChatUploadDocument))
+
+	msg := tgbotapi.NewDocumentUpload(chatID, tgbotapi.FileReader{Name: name, Reader: reader, Size: size})
+	msg.Caption = hideSecrets(name)
+	_, err := send(bot, msg)
+	if err != nil {
+		logger.WithError(err).WithField("chat_id", chatID).Error("Unable to upload the file")
+		sendMessageTo(bot, chatID, fmt.Sprintf("Unable to send you the file\n`Error: %s`", err.Error()))
+	}
+}
+
+// Send the content of a file from the repository as a chat message, optionally from the commit of rev
+func sendFileContent(bot *tgbotapi.BotAPI, chatID int64, file string, rev string) {
+	content, err := readFile(file, rev)
+	if err != nil {
+		logger.WithError(err).WithField("chat_id", chatID).Warn("Unable to read the file")
+		sendMessageTo(bot, chatID, fmt.Sprintf("An error occoured while reading a file.\n`Error: %s`", err.Error()))
+		return
+	}
+
+	_, filename := filepath.Split(file)
+	message := fmt.Sprintf("*%s*\n```%s```", filename, string(content))
+	sendMessageTo(bot, chatID, message)
+}
+
+// Announce a new or corrected exercise sheet with its files attached
+func sendExerciseAnnouncement(bot *tgbotapi.BotAPI, chatID int64, announcement exerciseAnnouncement) {
+	names := make([]string, 0, len(announcement.Files))
+	for _, file := range announcement.Files {
+		names = append(names, path.Base(file))
+	}
+
+	message := fmt.Sprintf("📢 *Exercise %d is out!*\nFiles: %s", announcement.Number, strings.Join(names, ", "))
+	if !announcement.Released {
+		message = fmt.Sprintf("✏️ *Exercise %d got corrected*\nChanged files: %s", announcement.Number, strings.Join(names, ", "))
+	}
+	for _, due := range announcement.Deadlines {
+		message += fmt.Sprintf("\n⏰ Deadline: %s", formatDue(due))
+	}
+	sendMessageTo(bot, chatID, message)
+
+	// Show what changed in the text of the corrected sheets, so nobody has to compare them by hand
+	for _, file := range announcement.Files {
+		changes, ok := announcement.Diffs[file]
+		if !ok {
+			continue
+		}
+		if changes == "" {
+			sendMessageTo(bot, chatID, fmt.Sprintf("The text of %s did not change, only the layout.", path.Base(file)))
+			continue
+		}
+		sendMessageTo(bot, chatID, fmt.Sprintf("*Changes in %s:*\n```\n%s\n```", path.Base(file),
+			strings.ReplaceAll(changes, "`", "'")))
+	}
+
+	// The files are sent as they were in the commit of the announcement, even if they changed again in the meantime
+	for _, file := range announcement.Files {
+		content, err := readFile(file, announcement.To)
+		if err != nil {
+			logger.WithError(err).WithField("file", file).Warn("Unable to read the exercise file")
+			continue
+		}
+		sendBytesTo(bot, chatID, path.Base(file), content)
 	}
 }
 
@@ -534,6 +1198,138 @@ func sendAction(bot *tgbotapi.BotAPI, update *tgbotapi.Update, action string) {
 	_, _ = bot.Send(tgbotapi.NewChatAction(update.Message.Chat.ID, action))
 }
 
+// Returns true if the commit was made by the user of the git URL (the one with the matriculation number)
+func isOwnCommit(commit gitobject.Commit) bool {
+	return isOwnEmail(commit.Author.Email)
+}
+
+// Remove the commits of the user, the user made them so why would they want to see them?
+func filterOwnCommits(commits []gitobject.Commit) []gitobject.Commit {
+	filtered := make([]gitobject.Commit, 0, len(commits))
+	for _, c := range commits {
+		if isOwnCommit(c) {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered
+}
+
+// Create one message for each branch or tag that changed with a pull.
+// The current branch is skipped because its new commits are already in the normal "New commits" message. If own is
+// false, the changes that only consist of commits by the user or private commits are skipped as well.
+func formatRefChanges(changes []refChange, own bool) []string {
+	current, _ := getCurrentBranch()
+
+	messages := make([]string, 0, len(changes))
+	for _, change := range changes {
+		switch change.Kind {
+		case branchUpdated, branchRewritten:
+			if change.Name == current {
+				continue
+			}
+
+			commits, err := historyBetween(change.Old.String(), change.New.String())
+			if err != nil {
+				logger.WithError(err).WithField("ref", change.Name).Warn("Unable to load the new commits")
+				continue
+			}
+			if !own {
+				commits, _ = splitPrivateCommits(filterOwnCommits(commits))
+			}
+			if len(commits) == 0 {
+				continue
+			}
+
+			message := fmt.Sprintf("🌿 *New commits on branch* `%s`:\n", change.Name)
+			for _, commit := range commits {
+				message += formatCommit(commit)
+			}
+			messages = append(messages, message)
+
+		case branchCreated, tagCreated, tagMoved:
+			commit, err := refCommit(change.New)
+			if err != nil {
+				logger.WithError(err).WithField("ref", change.Name).Warn("Unable to load the commit")
+				continue
+			}
+			if (!own && (isOwnCommit(*commit) || isPrivateCommit(*commit))) ||
+				(change.Kind == branchCreated && change.Name == current) {
+				continue
+			}
+
+			header := ""
+			switch change.Kind {
+			case branchCreated:
+				header = fmt.Sprintf("🌱 *New branch* `%s`", change.Name)
+			case tagCreated:
+				header = fmt.Sprintf("🏷 *New tag* `%s`", change.Name)
+			case tagMoved:
+				// Annotated tags point to a tag object, but the users know the commits
+				old := change.Old
+				if oldCommit, err := refCommit(change.Old); err == nil {
+					old = oldCommit.Hash
+				}
+				header = fmt.Sprintf("🏷 *Tag* `%s` *was moved* (it was on `%s` before)", change.Name, old.String()[:7])
+			}
+			messages = append(messages, header+"\n\n"+formatCommit(*commit))
+
+		case branchDeleted:
+			messages = append(messages, fmt.Sprintf("🗑 *Branch* `%s` *was deleted*", change.Name))
+		case tagDeleted:
+			messages = append(messages, fmt.Sprintf("🗑 *Tag* `%s` *was deleted*", change.Name))
+		}
+	}
+
+	return messages
+}
+
+// Create a message for the admin for each branch whose history was rewritten, with the commits that were removed
+// and added by the rewrite
+func formatRewrites(changes []refChange) []string {
+	messages := make([]string, 0)
+	for _, change := range changes {
+		if change.Kind != branchRewritten {
+			continue
+		}
+
+		message := fmt.Sprintf("⚠️ *The history of branch* `%s` *was rewritten*\n", change.Name)
+		message += fmt.Sprintf("It was on `%s` and is now on `%s`.\n", change.Old.String()[:7], change.New.String()[:7])
+
+		removed, err := historyRange(change.New, change.Old)
+		if err != nil {
+			logger.WithError(err).WithField("ref", change.Name).Warn("Unable to load the removed commits")
+			continue
+		}
+		added, err := historyRange(change.Old, change.New)
+		if err != nil {
+			logger.WithError(err).WithField("ref", change.Name).Warn("Unable to load the added commits")
+			continue
+		}
+
+		message += fmt.Sprintf("\n*Removed commits* \\[%d]\n%s", len(removed), formatCommitList(removed))
+		message += fmt.Sprintf("\n*Added commits* \\[%d]\n%s", len(added), formatCommitList(added))
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// Format the commits as a short list with one line per commit, long lists are cut off
+func formatCommitList(commits []gitobject.Commit) string {
+	const limit = 15
+
+	text := ""
+	for i, commit := range commits {
+		if i == limit {
+			text += fmt.Sprintf("_... and %d more_\n", len(commits)-limit)
+			break
+		}
+		title := strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0]
+		text += fmt.Sprintf("`%s` %s\n", commit.Hash.String()[:7], title)
+	}
+	return text
+}
+
 func formatCommit(commit gitobject.Commit) string {
 	// Get the files from the commit
 	var files []string
@@ -569,17 +1365,6 @@ func formatCommit(commit gitobject.Commit) string {
 	)
 }
 
-func hideSecrets(text string) string {
-	text = strings.ReplaceAll(text, os.Getenv("GIT_URL"), "$GIT_URL")
-
-	// Only replace the username if there is a username in the url.
-	userName := getGitUser()
-	if userName != "" {
-		text = strings.ReplaceAll(text, getGitUser(), "$USER")
-	}
-	return text
-}
-
 func getAdmin() int {
 	id, _ := strconv.ParseInt(os.Getenv("TELEGRAM_ADMIN"), 10, 32)
 	return int(id)
@@ -3,7 +3,6 @@ package main
 import (
 	"encoding/json"
 	"io/ioutil"
-	"log"
 	"path"
 	"sync"
 )
@@ -26,7 +25,7 @@ func loadUsers() error {
 	// Read the file
 	byteValue, err := ioutil.ReadFile(userFile)
 	if err != nil {
-		log.Println("The user file does not exist")
+		logger.Info("The user file does not exist")
 		return nil
 	}
 
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The checks the validator can run
+const (
+	// Files the tutors put into the submission directory (the template) that are missing now
+	checkTemplate = "template"
+	// Files the user committed that are empty
+	checkEmpty = "empty"
+	// Files listed in VALIDATE_REQUIRED_FILES that are missing
+	checkRequired = "required"
+)
+
+var (
+	// The problems the admin was told about last, so the same problems are not reported after every pull
+	reportedProblems = make([]string, 0)
+
+	validationMutex = sync.Mutex{}
+	validationFile  = path.Join("data", "validation.json")
+)
+
+// The checks that are enabled, set with VALIDATE_CHECKS as a comma separated list (default: all of them).
+// With VALIDATE_CHECKS=none the validator is disabled.
+func validationChecks() (map[string]bool, error) {
+	value := os.Getenv("VALIDATE_CHECKS")
+	if value == "" {
+		value = strings.Join([]string{checkTemplate, checkEmpty, checkRequired}, ",")
+	}
+
+	checks := make(map[string]bool)
+	for _, check := range strings.Split(value, ",") {
+		check = strings.ToLower(strings.TrimSpace(check))
+		switch check {
+		case "", "none":
+		case checkTemplate, checkEmpty, checkRequired:
+			checks[check] = true
+		default:
+			return nil, fmt.Errorf("there is no check called %s", check)
+		}
+	}
+	return checks, nil
+}
+
+// The files every submission needs, like "build.gradle,src/Main.java", relative to the submission directory.
+// They are set with VALIDATE_REQUIRED_FILES.
+func requiredFiles() []string {
+	files := make([]string, 0)
+	for _, file := range strings.Split(os.Getenv("VALIDATE_REQUIRED_FILES"), ",") {
+		file = strings.Trim(strings.TrimSpace(file), "/")
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// Check if a file exists in the working tree
+func fileExists(file string) bool {
+	_, err := os.Stat(path.Join(getGitDir(), file))
+	return err == nil
+}
+
+// Check the submissions of all exercises the user already committed to. Each problem is one line for the message.
+func validateSubmissions() ([]string, error) {
+	checks, err := validationChecks()
+	if err != nil || len(checks) == 0 {
+		return nil, err
+	}
+
+	head, err := getCurrentCommit()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := reachableCommits(plumbing.NewHash(head))
+	if err != nil {
+		return nil, err
+	}
+	sortCommits(commits, orderTopological)
+
+	// The exercises are the ones with sheets or deadlines, some courses have no directory with the sheets
+	numbers := make(map[int]bool)
+	exercises, err := exerciseCatalog()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range exercises {
+		numbers[e.Number] = true
+	}
+	for _, d := range getDeadlines() {
+		numbers[d.Exercise] = true
+	}
+	sorted := make([]int, 0, len(numbers))
+	for number := range numbers {
+		sorted = append(sorted, number)
+	}
+	sort.Ints(sorted)
+
+	problems := make([]string, 0)
+	for _, number := range sorted {
+		dir := submissionDir(number)
+
+		// Go through the history of the directory: the files the tutors added are the template and the files the
+		// user changed are the submission
+		template := make(map[string]bool)
+		own := make(map[string]bool)
+		for _, c := range commits {
+			if !c.touches(dir) {
+				continue
+			}
+			for _, file := range c.Files {
+				if file != dir && !strings.HasPrefix(file, dir+"/") {
+					continue
+				}
+				if c.isOwn() {
+					own[file] = true
+					continue
+				}
+				// Files the tutors deleted again are no longer part of the template
+				_, err := readFile(file, c.Hash)
+				template[file] = err == nil
+			}
+		}
+		// Nothing to check before the user started with the exercise
+		if len(own) == 0 {
+			continue
+		}
+
+		found := make([]string, 0)
+		if checks[checkTemplate] {
+			for file, exists := range template {
+				if exists && !fileExists(file) {
+					found = append(found, fmt.Sprintf("`%s` from the template is missing", file))
+				}
+			}
+		}
+		if checks[checkEmpty] {
+			for file := range own {
+				content, err := readFile(file, "")
+				if err == nil && strings.TrimSpace(string(content)) == "" {
+					found = append(found, fmt.Sprintf("`%s` is empty", file))
+				}
+			}
+		}
+		if checks[checkRequired] {
+			for _, file := range requiredFiles() {
+				if !fileExists(path.Join(dir, file)) {
+					found = append(found, fmt.Sprintf("`%s` is missing", path.Join(dir, file)))
+				}
+			}
+		}
+
+		sort.Strings(found)
+		for _, problem := range found {
+			problems = append(problems, fmt.Sprintf("Exercise %d: %s", number, problem))
+		}
+	}
+	return problems, nil
+}
+
+// Load the reported problems from the disk.
+// This function should be called once
+func loadValidation() error {
+	validationMutex.Lock()
+	defer validationMutex.Unlock()
+
+	byteValue, err := ioutil.ReadFile(validationFile)
+	if err != nil {
+		logger.Info("The validation file does not exist")
+		return nil
+	}
+
+	return json.Unmarshal(byteValue, &reportedProblems)
+}
+
+// Save the reported problems to the disk
+// Note: the caller must lock the validationMutex to avoid race conditions
+func saveValidation() error {
+	byteValue, err := json.Marshal(reportedProblems)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(validationFile, byteValue, 0777)
+}
+
+// Check the own submissions after a pull and tell the admin about the problems. The admin only gets a message if the
+// problems changed since the last report, so the same problems are not sent after every pull.
+func runValidation(bot *tgbotapi.BotAPI) {
+	if checks, _ := validationChecks(); len(checks) == 0 {
+		return
+	}
+
+	log := jobLog("validate")
+	problems, err := validateSubmissions()
+	if err != nil {
+		log.WithError(err).Error("Unable to validate the submissions")
+		return
+	}
+
+	validationMutex.Lock()
+	defer validationMutex.Unlock()
+
+	if strings.Join(problems, "\n") == strings.Join(reportedProblems, "\n") {
+		return
+	}
+
+	if len(problems) == 0 {
+		sendMessageTo(bot, int64(getAdmin()), "✅ *The problems in your submissions are fixed*")
+	} else {
+		sendMessageTo(bot, int64(getAdmin()), "🔍 *Problems in your submissions:*\n"+strings.Join(problems, "\n"))
+	}
+	log.WithField("problems", len(problems)).Info("Reported the problems of the submissions")
+
+	reportedProblems = problems
+	err = saveValidation()
+	if err != nil {
+		log.WithError(err).Error("Unable to save the reported problems")
+	}
+}